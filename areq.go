@@ -0,0 +1,349 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/*
+AReq message EMV 3-D Secure 2.x (fields required by device channel & message category):
+------------------------------------------------------------------------------------------------------------------------
+|  Field                              |  Required                                              |   Format              |
+------------------------------------------------------------------------------------------------------------------------
+|  messageType, messageVersion        |  always                                                |   AReq, 2.x.x         |
+|  threeDSServerTransID               |  always                                                |   UUID (36 chars)     |
+|  threeDSServerRefNumber             |  always                                                |   1-32 chars          |
+|  threeDSRequestorID / Name / URL    |  always                                                |   35 / 40 / 2048      |
+|  acctNumber                         |  always                                                |   13-19 digits        |
+|  deviceChannel                      |  always: 01 - APP, 02 - BRW, 03 - 3RI                  |   2 digits            |
+|  messageCategory                    |  always: 01 - PA, 02 - NPA                             |   2 digits            |
+------------------------------------------------------------------------------------------------------------------------
+|  threeDSRequestorAuthenticationInd  |  APP, BRW                                              |   2 digits            |
+|  threeDSServerURL                   |  APP, BRW                                              |   max 2048            |
+|  threeRIInd                         |  3RI                                                   |   2 digits            |
+------------------------------------------------------------------------------------------------------------------------
+|  acquirerBIN, acquirerMerchantID    |  PA                                                    |   11 / 35 chars       |
+|  mcc, merchantCountryCode           |  PA                                                    |   4 / 3 digits        |
+|  merchantName                       |  PA                                                    |   max 40 chars        |
+|  purchaseAmount, purchaseCurrency   |  PA                                                    |   48 / 3 digits       |
+|  purchaseExponent, purchaseDate     |  PA                                                    |   1 / 14 digits       |
+------------------------------------------------------------------------------------------------------------------------
+|  threeDSCompInd, notificationURL    |  BRW                                                   |   Y/N/U, max 256      |
+|  browserAcceptHeader                |  BRW                                                   |   max 2048            |
+|  browserLanguage, browserUserAgent  |  BRW                                                   |   8 / 2048            |
+|  browserJavascriptEnabled           |  BRW 2.2.0+                                            |   boolean             |
+|  browserJavaEnabled, browserTZ      |  BRW 2.1.0, BRW 2.2.0+ with JavaScript enabled         |   boolean, 1-5 chars  |
+|  browserColorDepth, browserScreen*  |  BRW 2.1.0, BRW 2.2.0+ with JavaScript enabled         |   1-2 / 1-6 digits    |
+------------------------------------------------------------------------------------------------------------------------
+|  sdkAppID, sdkTransID               |  APP                                                   |   UUID (36 chars)     |
+|  sdkEncData, sdkEphemPubKey         |  APP                                                   |   JWE, JWK            |
+|  sdkMaxTimeout, sdkReferenceNumber  |  APP                                                   |   2 digits (>=05), 32 |
+|  deviceRenderOptions                |  APP                                                   |   object              |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	// Purchase date format (UTC)
+	EMV3DS_DATE_FORMAT string = "20060102150405"
+)
+
+// =============================================================================
+//  SDK rendering options supported by device (APP channel)
+// =============================================================================
+type EMV3DSDeviceRenderOptions struct {
+	SDKInterface string   `json:"sdkInterface"` /* 01 - Native, 02 - HTML, 03 - Both */
+	SDKUIType    []string `json:"sdkUiType"`    /* 01 - Text ... 05 - HTML Other    */
+}
+
+// =============================================================================
+//  AReq (Authentication Request) EMV 3-D Secure 2.x
+// =============================================================================
+type AReq struct {
+	MessageType    string `json:"messageType"`
+	MessageVersion string `json:"messageVersion"`
+
+	ThreeDSCompInd                    string `json:"threeDSCompInd,omitempty"`
+	ThreeDSRequestorAuthenticationInd string `json:"threeDSRequestorAuthenticationInd,omitempty"`
+	ThreeDSRequestorID                string `json:"threeDSRequestorID"`
+	ThreeDSRequestorName              string `json:"threeDSRequestorName"`
+	ThreeDSRequestorURL               string `json:"threeDSRequestorURL"`
+	ThreeDSServerRefNumber            string `json:"threeDSServerRefNumber"`
+	ThreeDSServerOperatorID           string `json:"threeDSServerOperatorID,omitempty"`
+	ThreeDSServerTransID              string `json:"threeDSServerTransID"`
+	ThreeDSServerURL                  string `json:"threeDSServerURL,omitempty"`
+	ThreeRIInd                        string `json:"threeRIInd,omitempty"`
+
+	AcctType           string `json:"acctType,omitempty"`
+	AcquirerBIN        string `json:"acquirerBIN,omitempty"`
+	AcquirerMerchantID string `json:"acquirerMerchantID,omitempty"`
+	AcctNumber         string `json:"acctNumber"`
+	CardExpiryDate     string `json:"cardExpiryDate,omitempty"`
+	CardholderName     string `json:"cardholderName,omitempty"`
+	Email              string `json:"email,omitempty"`
+
+	DeviceChannel   string `json:"deviceChannel"`
+	MessageCategory string `json:"messageCategory"`
+
+	DSReferenceNumber string `json:"dsReferenceNumber,omitempty"`
+	DSTransID         string `json:"dsTransID,omitempty"`
+	DSURL             string `json:"dsURL,omitempty"`
+
+	MCC                 string `json:"mcc,omitempty"`
+	MerchantCountryCode string `json:"merchantCountryCode,omitempty"`
+	MerchantName        string `json:"merchantName,omitempty"`
+	NotificationURL     string `json:"notificationURL,omitempty"`
+
+	PurchaseAmount   string `json:"purchaseAmount,omitempty"`
+	PurchaseCurrency string `json:"purchaseCurrency,omitempty"`
+	PurchaseExponent string `json:"purchaseExponent,omitempty"`
+	PurchaseDate     string `json:"purchaseDate,omitempty"`
+
+	// Browser channel
+	BrowserAcceptHeader      string `json:"browserAcceptHeader,omitempty"`
+	BrowserIP                string `json:"browserIP,omitempty"`
+	BrowserJavaEnabled       *bool  `json:"browserJavaEnabled,omitempty"`
+	BrowserJavascriptEnabled *bool  `json:"browserJavascriptEnabled,omitempty"`
+	BrowserLanguage          string `json:"browserLanguage,omitempty"`
+	BrowserColorDepth        string `json:"browserColorDepth,omitempty"`
+	BrowserScreenHeight      string `json:"browserScreenHeight,omitempty"`
+	BrowserScreenWidth       string `json:"browserScreenWidth,omitempty"`
+	BrowserTZ                string `json:"browserTZ,omitempty"`
+	BrowserUserAgent         string `json:"browserUserAgent,omitempty"`
+
+	// App channel
+	DeviceInfo          string                     `json:"deviceInfo,omitempty"`
+	DeviceRenderOptions *EMV3DSDeviceRenderOptions `json:"deviceRenderOptions,omitempty"`
+	SDKAppID            string                     `json:"sdkAppID,omitempty"`
+	SDKEncData          string                     `json:"sdkEncData,omitempty"`
+	SDKEphemPubKey      json.RawMessage            `json:"sdkEphemPubKey,omitempty"`
+	SDKMaxTimeout       string                     `json:"sdkMaxTimeout,omitempty"`
+	SDKReferenceNumber  string                     `json:"sdkReferenceNumber,omitempty"`
+	SDKTransID          string                     `json:"sdkTransID,omitempty"`
+
+	MessageExtension []EMV3DSMessageExtension `json:"messageExtension,omitempty"`
+}
+
+// =============================================================================
+//  Check AReq is payment authentication
+// =============================================================================
+func (r *AReq) IsPayment() bool {
+	return r.MessageCategory == EMV3DS_CATEGORY_PA
+}
+
+// =============================================================================
+//  Validate AReq required fields for device channel & message category
+// =============================================================================
+func (r *AReq) Validate() error {
+	if err := checkEMV3DSHeader(r.MessageType, "AReq", r.MessageVersion); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("threeDSServerTransID", r.ThreeDSServerTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("threeDSServerRefNumber", r.ThreeDSServerRefNumber, 1, 32); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("threeDSRequestorID", r.ThreeDSRequestorID, 1, 35); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("threeDSRequestorName", r.ThreeDSRequestorName, 1, 40); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("threeDSRequestorURL", r.ThreeDSRequestorURL, 1, 2048); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("acctNumber", r.AcctNumber, 13, 19); err != nil {
+		return err
+	}
+	if r.CardExpiryDate != "" && (!isDigits(r.CardExpiryDate) || len(r.CardExpiryDate) != 4) {
+		return fmt.Errorf("Invalid EMV 3-D Secure field cardExpiryDate: %q", r.CardExpiryDate)
+	}
+	if err := checkEMV3DSEnum("deviceChannel", r.DeviceChannel, EMV3DS_CHANNEL_APP, EMV3DS_CHANNEL_BRW, EMV3DS_CHANNEL_3RI); err != nil {
+		return err
+	}
+	if err := checkEMV3DSEnum("messageCategory", r.MessageCategory, EMV3DS_CATEGORY_PA, EMV3DS_CATEGORY_NPA); err != nil {
+		return err
+	}
+	if r.DSTransID != "" {
+		if err := checkEMV3DSTransID("dsTransID", r.DSTransID); err != nil {
+			return err
+		}
+	}
+
+	switch r.DeviceChannel {
+	case EMV3DS_CHANNEL_APP, EMV3DS_CHANNEL_BRW:
+		if err := checkEMV3DSDigits("threeDSRequestorAuthenticationInd", r.ThreeDSRequestorAuthenticationInd, 2, 2); err != nil {
+			return err
+		}
+		if err := checkEMV3DSField("threeDSServerURL", r.ThreeDSServerURL, 1, 2048); err != nil {
+			return err
+		}
+	case EMV3DS_CHANNEL_3RI:
+		if err := checkEMV3DSDigits("threeRIInd", r.ThreeRIInd, 2, 2); err != nil {
+			return err
+		}
+	}
+	switch r.DeviceChannel {
+	case EMV3DS_CHANNEL_APP:
+		if err := r.validateApp(); err != nil {
+			return err
+		}
+	case EMV3DS_CHANNEL_BRW:
+		if err := r.validateBrowser(); err != nil {
+			return err
+		}
+	}
+	if r.IsPayment() {
+		if err := r.validatePurchase(); err != nil {
+			return err
+		}
+	}
+	return validateEMV3DSExtensions(r.MessageExtension)
+}
+
+// =============================================================================
+//  Helper function to validate browser channel fields
+// =============================================================================
+func (r *AReq) validateBrowser() error {
+	if err := checkEMV3DSEnum("threeDSCompInd", r.ThreeDSCompInd, "Y", "N", "U"); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("notificationURL", r.NotificationURL, 1, 256); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("browserAcceptHeader", r.BrowserAcceptHeader, 1, 2048); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("browserLanguage", r.BrowserLanguage, 1, 8); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("browserUserAgent", r.BrowserUserAgent, 1, 2048); err != nil {
+		return err
+	}
+	if err := checkEMV3DSOptional("browserIP", r.BrowserIP, 1, 45); err != nil {
+		return err
+	}
+	// JavaScript dependent fields are required for 2.1.0 and when JavaScript is enabled
+	jsFields := r.MessageVersion == EMV3DS_VER_210
+	if r.MessageVersion != EMV3DS_VER_210 {
+		if r.BrowserJavascriptEnabled == nil {
+			return fmt.Errorf("Required EMV 3-D Secure field browserJavascriptEnabled is missing")
+		}
+		jsFields = *r.BrowserJavascriptEnabled
+	}
+	if !jsFields {
+		return nil
+	}
+	if r.BrowserJavaEnabled == nil {
+		return fmt.Errorf("Required EMV 3-D Secure field browserJavaEnabled is missing")
+	}
+	if err := checkEMV3DSDigits("browserColorDepth", r.BrowserColorDepth, 1, 2); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("browserScreenHeight", r.BrowserScreenHeight, 1, 6); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("browserScreenWidth", r.BrowserScreenWidth, 1, 6); err != nil {
+		return err
+	}
+	return checkEMV3DSField("browserTZ", r.BrowserTZ, 1, 5)
+}
+
+// =============================================================================
+//  Helper function to validate app channel fields
+// =============================================================================
+func (r *AReq) validateApp() error {
+	if err := checkEMV3DSTransID("sdkAppID", r.SDKAppID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("sdkTransID", r.SDKTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("sdkEncData", r.SDKEncData, 1, 64000); err != nil {
+		return err
+	}
+	if len(r.SDKEphemPubKey) == 0 {
+		return fmt.Errorf("Required EMV 3-D Secure field sdkEphemPubKey is missing")
+	}
+	if err := checkEMV3DSDigits("sdkMaxTimeout", r.SDKMaxTimeout, 2, 2); err != nil {
+		return err
+	}
+	if r.SDKMaxTimeout < "05" {
+		return fmt.Errorf("Invalid EMV 3-D Secure field sdkMaxTimeout: %q", r.SDKMaxTimeout)
+	}
+	if err := checkEMV3DSField("sdkReferenceNumber", r.SDKReferenceNumber, 1, 32); err != nil {
+		return err
+	}
+	if r.DeviceRenderOptions == nil {
+		return fmt.Errorf("Required EMV 3-D Secure field deviceRenderOptions is missing")
+	}
+	if err := checkEMV3DSEnum("deviceRenderOptions.sdkInterface", r.DeviceRenderOptions.SDKInterface, "01", "02", "03"); err != nil {
+		return err
+	}
+	if len(r.DeviceRenderOptions.SDKUIType) == 0 {
+		return fmt.Errorf("Required EMV 3-D Secure field deviceRenderOptions.sdkUiType is missing")
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to validate merchant & purchase fields (payment)
+// =============================================================================
+func (r *AReq) validatePurchase() error {
+	if err := checkEMV3DSDigits("acquirerBIN", r.AcquirerBIN, 1, 11); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("acquirerMerchantID", r.AcquirerMerchantID, 1, 35); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("mcc", r.MCC, 4, 4); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("merchantCountryCode", r.MerchantCountryCode, 3, 3); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("merchantName", r.MerchantName, 1, 40); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("purchaseAmount", r.PurchaseAmount, 1, 48); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("purchaseCurrency", r.PurchaseCurrency, 3, 3); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("purchaseExponent", r.PurchaseExponent, 1, 1); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("purchaseDate", r.PurchaseDate, 14, 14); err != nil {
+		return err
+	}
+	if _, err := time.Parse(EMV3DS_DATE_FORMAT, r.PurchaseDate); err != nil {
+		return fmt.Errorf("Invalid EMV 3-D Secure field purchaseDate: %q", r.PurchaseDate)
+	}
+	return nil
+}
+
+// =============================================================================
+//  Validate & marshal AReq to JSON
+// =============================================================================
+func (r *AReq) Marshal() ([]byte, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// =============================================================================
+//  Parse & validate AReq JSON message
+// =============================================================================
+func UnmarshalAReq(data []byte) (*AReq, error) {
+	r := &AReq{}
+	if err := unmarshalEMV3DSMessage(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,142 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const (
+	TEST_3DS_SERVER_TRANS_ID string = "8a880dc0-d2d2-4067-bcb1-b08d1690b26e"
+	TEST_3DS_ACS_TRANS_ID    string = "d7c1ee99-9478-44a6-b1f2-391e29c6b340"
+	TEST_3DS_DS_TRANS_ID     string = "f25084f0-5b16-4c0a-ae5d-b24808a95e4b"
+	TEST_3DS_SDK_TRANS_ID    string = "b2385523-a66c-4907-ac3c-91848e8c0067"
+)
+
+// =============================================================================
+//  Helper function to create browser payment AReq
+// =============================================================================
+func testAReqBrowser(version string) *AReq {
+	js := true
+	java := false
+	return &AReq{
+		MessageType:                       "AReq",
+		MessageVersion:                    version,
+		ThreeDSCompInd:                    "Y",
+		ThreeDSRequestorAuthenticationInd: "01",
+		ThreeDSRequestorID:                "10075020",
+		ThreeDSRequestorName:              "Test Merchant",
+		ThreeDSRequestorURL:               "https://merchant.example.com",
+		ThreeDSServerRefNumber:            "3DS_LOA_SER_TEST_020100_00001",
+		ThreeDSServerTransID:              TEST_3DS_SERVER_TRANS_ID,
+		ThreeDSServerURL:                  "https://3dss.example.com/results",
+		AcquirerBIN:                       "2201380114",
+		AcquirerMerchantID:                "07070707",
+		AcctNumber:                        TEST_V_PAN_16,
+		CardExpiryDate:                    "2512",
+		DeviceChannel:                     EMV3DS_CHANNEL_BRW,
+		MessageCategory:                   EMV3DS_CATEGORY_PA,
+		MCC:                               "5411",
+		MerchantCountryCode:               "840",
+		MerchantName:                      "AO PP_E-COMMERCE",
+		NotificationURL:                   "https://merchant.example.com/notify",
+		PurchaseAmount:                    "100",
+		PurchaseCurrency:                  "840",
+		PurchaseExponent:                  "2",
+		PurchaseDate:                      "20180629173512",
+		BrowserAcceptHeader:               "text/html",
+		BrowserIP:                         "192.168.1.11",
+		BrowserJavaEnabled:                &java,
+		BrowserJavascriptEnabled:          &js,
+		BrowserLanguage:                   "en-US",
+		BrowserColorDepth:                 "24",
+		BrowserScreenHeight:               "1080",
+		BrowserScreenWidth:                "1920",
+		BrowserTZ:                         "-180",
+		BrowserUserAgent:                  "Mozilla/5.0",
+	}
+}
+
+// =============================================================================
+//  Helper function to create app payment AReq
+// =============================================================================
+func testAReqApp(version string) *AReq {
+	r := testAReqBrowser(version)
+	r.DeviceChannel = EMV3DS_CHANNEL_APP
+	r.ThreeDSCompInd, r.NotificationURL = "", ""
+	r.BrowserAcceptHeader, r.BrowserIP, r.BrowserJavaEnabled, r.BrowserJavascriptEnabled = "", "", nil, nil
+	r.BrowserLanguage, r.BrowserColorDepth, r.BrowserScreenHeight, r.BrowserScreenWidth = "", "", "", ""
+	r.BrowserTZ, r.BrowserUserAgent = "", ""
+	r.SDKAppID = "dbd64fcb-c19a-4728-8849-e3d50bfdde39"
+	r.SDKEncData = "eyJhbGciOiJSU0EtT0FFUC0yNTYiLCJlbmMiOiJBMTI4Q0JDLUhTMjU2In0.."
+	r.SDKEphemPubKey = json.RawMessage(`{"kty":"EC","crv":"P-256","x":"mJ0yRrLfgHO5aYcTIu0pEM1FsJBfw0PNSe_wanRTXnk","y":"E6gvW7_j5wvGgNyTn3Avw9O4SzP8d3xWpHDdPz9bBTk"}`)
+	r.SDKMaxTimeout = "05"
+	r.SDKReferenceNumber = "3DS_LOA_SDK_TEST_020100_00001"
+	r.SDKTransID = TEST_3DS_SDK_TRANS_ID
+	r.DeviceRenderOptions = &EMV3DSDeviceRenderOptions{SDKInterface: "03", SDKUIType: []string{"01", "02", "03", "04", "05"}}
+	return r
+}
+
+// =============================================================================
+// Test AReq marshalling for all versions & channels
+// =============================================================================
+func TestAReqMarshal(t *testing.T) {
+	for _, v := range []string{EMV3DS_VER_210, EMV3DS_VER_220, EMV3DS_VER_231} {
+		for _, r := range []*AReq{testAReqBrowser(v), testAReqApp(v)} {
+			data, err := r.Marshal()
+			if err != nil {
+				t.Fatalf("[EMV3DS]: Failed to marshal AReq %s/%s: %s\n", v, r.DeviceChannel, err)
+			}
+			u, err := UnmarshalAReq(data)
+			if err != nil {
+				t.Fatalf("[EMV3DS]: Failed to unmarshal AReq %s/%s: %s\n", v, r.DeviceChannel, err)
+			}
+			if u.AcctNumber != r.AcctNumber || u.DeviceChannel != r.DeviceChannel || string(u.SDKEphemPubKey) != string(r.SDKEphemPubKey) {
+				t.Fatalf("[EMV3DS]: Invalid AReq after round trip: %+v\n", u)
+			}
+		}
+	}
+	data, _ := testAReqApp(EMV3DS_VER_220).Marshal()
+	if strings.Contains(string(data), "browserJavaEnabled") || !strings.Contains(string(data), `"sdkUiType":["01"`) {
+		t.Fatalf("[EMV3DS]: Invalid app AReq JSON: %s\n", data)
+	}
+}
+
+// =============================================================================
+// Test AReq required fields validation
+// =============================================================================
+func TestAReqValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		r    func() *AReq
+	}{
+		{"version", func() *AReq { r := testAReqBrowser(EMV3DS_VER_220); r.MessageVersion = "2.0.0"; return r }},
+		{"threeDSServerTransID", func() *AReq { r := testAReqBrowser(EMV3DS_VER_220); r.ThreeDSServerTransID = "123"; return r }},
+		{"notificationURL", func() *AReq { r := testAReqBrowser(EMV3DS_VER_220); r.NotificationURL = ""; return r }},
+		{"browserJavascriptEnabled", func() *AReq { r := testAReqBrowser(EMV3DS_VER_220); r.BrowserJavascriptEnabled = nil; return r }},
+		{"browserJavaEnabled 2.1.0", func() *AReq { r := testAReqBrowser(EMV3DS_VER_210); r.BrowserJavaEnabled = nil; return r }},
+		{"sdkEphemPubKey", func() *AReq { r := testAReqApp(EMV3DS_VER_231); r.SDKEphemPubKey = nil; return r }},
+		{"sdkMaxTimeout", func() *AReq { r := testAReqApp(EMV3DS_VER_231); r.SDKMaxTimeout = "04"; return r }},
+		{"purchaseDate", func() *AReq { r := testAReqApp(EMV3DS_VER_231); r.PurchaseDate = "20181329173512"; return r }},
+		{"threeRIInd", func() *AReq { r := testAReqBrowser(EMV3DS_VER_220); r.DeviceChannel = EMV3DS_CHANNEL_3RI; return r }},
+	}
+	for _, tc := range tests {
+		if err := tc.r().Validate(); err == nil {
+			t.Fatalf("[EMV3DS]: Validated AReq with invalid %s\n", tc.name)
+		}
+	}
+
+	// Browser fields depending on JavaScript are not required when it is disabled
+	r := testAReqBrowser(EMV3DS_VER_220)
+	js := false
+	r.BrowserJavascriptEnabled, r.BrowserJavaEnabled, r.BrowserColorDepth, r.BrowserTZ = &js, nil, "", ""
+	if err := r.Validate(); err != nil {
+		t.Fatalf("[EMV3DS]: Failed to validate AReq with JavaScript disabled: %s\n", err)
+	}
+	// Purchase fields are not required for non-payment
+	r = testAReqBrowser(EMV3DS_VER_220)
+	r.MessageCategory, r.PurchaseAmount, r.MCC = EMV3DS_CATEGORY_NPA, "", ""
+	if err := r.Validate(); err != nil {
+		t.Fatalf("[EMV3DS]: Failed to validate non-payment AReq: %s\n", err)
+	}
+}
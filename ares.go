@@ -0,0 +1,223 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+ARes message EMV 3-D Secure 2.x (fields required by transaction status):
+------------------------------------------------------------------------------------------------------------------------
+|  Field                              |  Required                                              |   Format              |
+------------------------------------------------------------------------------------------------------------------------
+|  messageType, messageVersion        |  always                                                |   ARes, 2.x.x         |
+|  threeDSServerTransID               |  always, echoed from AReq                              |   UUID (36 chars)     |
+|  acsTransID, dsTransID              |  always                                                |   UUID (36 chars)     |
+|  acsReferenceNumber                 |  always                                                |   1-32 chars          |
+|  dsReferenceNumber                  |  always                                                |   1-32 chars          |
+|  transStatus                        |  always                                                |   Y N U A C D R I     |
+|  sdkTransID                         |  APP, echoed from AReq                                 |   UUID (36 chars)     |
+------------------------------------------------------------------------------------------------------------------------
+|  authenticationValue                |  transStatus Y, A (CAVV / AAV)                         |   28 chars (base64)   |
+|  eci                                |  transStatus Y, A (payment)                            |   2 digits            |
+|  transStatusReason                  |  transStatus N, U, R                                   |   2 digits            |
+|  acsChallengeMandated               |  transStatus C                                         |   Y/N                 |
+|  authenticationType                 |  transStatus C, D                                      |   2 digits            |
+|  acsURL                             |  transStatus C, BRW                                    |   max 2048            |
+|  acsSignedContent, acsRenderingType |  transStatus C, APP                                    |   JWS, object         |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+// =============================================================================
+//  ACS rendering type (APP challenge)
+// =============================================================================
+type EMV3DSRenderingType struct {
+	ACSInterface  string `json:"acsInterface"`  /* 01 - Native UI, 02 - HTML UI */
+	ACSUITemplate string `json:"acsUiTemplate"` /* 01 - Text ... 05 - HTML Other */
+}
+
+// =============================================================================
+//  ARes (Authentication Response) EMV 3-D Secure 2.x
+// =============================================================================
+type ARes struct {
+	MessageType    string `json:"messageType"`
+	MessageVersion string `json:"messageVersion"`
+
+	ThreeDSServerTransID string `json:"threeDSServerTransID"`
+	ACSTransID           string `json:"acsTransID"`
+	ACSReferenceNumber   string `json:"acsReferenceNumber"`
+	ACSOperatorID        string `json:"acsOperatorID,omitempty"`
+	DSReferenceNumber    string `json:"dsReferenceNumber"`
+	DSTransID            string `json:"dsTransID"`
+	SDKTransID           string `json:"sdkTransID,omitempty"`
+
+	TransStatus         string `json:"transStatus"`
+	TransStatusReason   string `json:"transStatusReason,omitempty"`
+	AuthenticationType  string `json:"authenticationType,omitempty"`
+	AuthenticationValue string `json:"authenticationValue,omitempty"`
+	ECI                 string `json:"eci,omitempty"`
+
+	ACSChallengeMandated string               `json:"acsChallengeMandated,omitempty"`
+	ACSDecConInd         string               `json:"acsDecConInd,omitempty"`
+	ACSRenderingType     *EMV3DSRenderingType `json:"acsRenderingType,omitempty"`
+	ACSSignedContent     string               `json:"acsSignedContent,omitempty"`
+	ACSURL               string               `json:"acsURL,omitempty"`
+	CardholderInfo       string               `json:"cardholderInfo,omitempty"`
+	BroadInfo            json.RawMessage      `json:"broadInfo,omitempty"`
+
+	MessageExtension []EMV3DSMessageExtension `json:"messageExtension,omitempty"`
+}
+
+// =============================================================================
+//  Validate ARes fields, with AReq the ARes must echo request transaction ids
+//  and the device channel & category dependent fields are checked
+// =============================================================================
+func (r *ARes) Validate(req *AReq) error {
+	if err := checkEMV3DSHeader(r.MessageType, "ARes", r.MessageVersion); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("threeDSServerTransID", r.ThreeDSServerTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("acsTransID", r.ACSTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("dsTransID", r.DSTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("acsReferenceNumber", r.ACSReferenceNumber, 1, 32); err != nil {
+		return err
+	}
+	if err := checkEMV3DSField("dsReferenceNumber", r.DSReferenceNumber, 1, 32); err != nil {
+		return err
+	}
+	if err := checkEMV3DSEnum("transStatus", r.TransStatus,
+		EMV3DS_TRANS_STATUS_Y, EMV3DS_TRANS_STATUS_N, EMV3DS_TRANS_STATUS_U, EMV3DS_TRANS_STATUS_A,
+		EMV3DS_TRANS_STATUS_C, EMV3DS_TRANS_STATUS_D, EMV3DS_TRANS_STATUS_R, EMV3DS_TRANS_STATUS_I); err != nil {
+		return err
+	}
+	if r.MessageVersion == EMV3DS_VER_210 && (r.TransStatus == EMV3DS_TRANS_STATUS_D || r.TransStatus == EMV3DS_TRANS_STATUS_I) {
+		return fmt.Errorf("Invalid EMV 3-D Secure transStatus %s for version %s", r.TransStatus, r.MessageVersion)
+	}
+
+	channel, payment := "", true
+	if req != nil {
+		if r.ThreeDSServerTransID != req.ThreeDSServerTransID {
+			return fmt.Errorf("ARes threeDSServerTransID %q does not match AReq: %q", r.ThreeDSServerTransID, req.ThreeDSServerTransID)
+		}
+		if r.MessageVersion != req.MessageVersion {
+			return fmt.Errorf("ARes messageVersion %q does not match AReq: %q", r.MessageVersion, req.MessageVersion)
+		}
+		if req.DeviceChannel == EMV3DS_CHANNEL_APP && r.SDKTransID != req.SDKTransID {
+			return fmt.Errorf("ARes sdkTransID %q does not match AReq: %q", r.SDKTransID, req.SDKTransID)
+		}
+		channel, payment = req.DeviceChannel, req.IsPayment()
+	}
+
+	switch r.TransStatus {
+	case EMV3DS_TRANS_STATUS_Y, EMV3DS_TRANS_STATUS_A:
+		if _, err := r.AuthenticationValueBytes(); err != nil {
+			return err
+		}
+		if payment {
+			if err := checkEMV3DSDigits("eci", r.ECI, 2, 2); err != nil {
+				return err
+			}
+		}
+	case EMV3DS_TRANS_STATUS_N, EMV3DS_TRANS_STATUS_U, EMV3DS_TRANS_STATUS_R:
+		if err := checkEMV3DSDigits("transStatusReason", r.TransStatusReason, 2, 2); err != nil {
+			return err
+		}
+	case EMV3DS_TRANS_STATUS_C:
+		if err := checkEMV3DSEnum("acsChallengeMandated", r.ACSChallengeMandated, "Y", "N"); err != nil {
+			return err
+		}
+		if err := checkEMV3DSDigits("authenticationType", r.AuthenticationType, 2, 2); err != nil {
+			return err
+		}
+		switch channel {
+		case EMV3DS_CHANNEL_BRW:
+			if err := checkEMV3DSField("acsURL", r.ACSURL, 1, 2048); err != nil {
+				return err
+			}
+		case EMV3DS_CHANNEL_APP:
+			if err := checkEMV3DSField("acsSignedContent", r.ACSSignedContent, 1, 512000); err != nil {
+				return err
+			}
+			if r.ACSRenderingType == nil {
+				return fmt.Errorf("Required EMV 3-D Secure field acsRenderingType is missing")
+			}
+		}
+	case EMV3DS_TRANS_STATUS_D:
+		if err := checkEMV3DSDigits("authenticationType", r.AuthenticationType, 2, 2); err != nil {
+			return err
+		}
+	}
+	return validateEMV3DSExtensions(r.MessageExtension)
+}
+
+// =============================================================================
+//  Decode authenticationValue (CAVV / AAV): 20 bytes, 21 bytes for Master
+//  Card SPA2 AAV
+// =============================================================================
+func (r *ARes) AuthenticationValueBytes() ([]byte, error) {
+	return decodeEMV3DSAuthValue(r.AuthenticationValue)
+}
+
+// =============================================================================
+//  Decode authenticationValue as VISA CAVV
+// =============================================================================
+func (r *ARes) VisaCavv() (*VisaCavvData, error) {
+	b, err := r.AuthenticationValueBytes()
+	if err != nil {
+		return nil, err
+	}
+	return ParseVisaCavv(b)
+}
+
+// =============================================================================
+//  Decode authenticationValue as Master Card SPA AAV
+// =============================================================================
+func (r *ARes) MasterCardAAV() (*MasterCardAAVData, error) {
+	b, err := r.AuthenticationValueBytes()
+	if err != nil {
+		return nil, err
+	}
+	return ParseMasterCardAAV(b)
+}
+
+// =============================================================================
+//  Set authenticationValue from CAVV / AAV generated by this package
+// =============================================================================
+func (r *ARes) SetAuthenticationValue(av []byte) error {
+	s, err := encodeEMV3DSAuthValue(av)
+	if err != nil {
+		return err
+	}
+	r.AuthenticationValue = s
+	return nil
+}
+
+// =============================================================================
+//  Validate & marshal ARes to JSON
+// =============================================================================
+func (r *ARes) Marshal(req *AReq) ([]byte, error) {
+	if err := r.Validate(req); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// =============================================================================
+//  Parse & validate ARes JSON message for AReq (may be nil)
+// =============================================================================
+func UnmarshalARes(data []byte, req *AReq) (*ARes, error) {
+	r := &ARes{}
+	if err := unmarshalEMV3DSMessage(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(req); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
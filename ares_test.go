@@ -0,0 +1,116 @@
+package gocavv
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+//  Helper function to create ARes for AReq
+// =============================================================================
+func testARes(req *AReq, status string) *ARes {
+	return &ARes{
+		MessageType:          "ARes",
+		MessageVersion:       req.MessageVersion,
+		ThreeDSServerTransID: req.ThreeDSServerTransID,
+		ACSTransID:           TEST_3DS_ACS_TRANS_ID,
+		ACSReferenceNumber:   "3DS_LOA_ACS_TEST_020100_00001",
+		DSReferenceNumber:    "3DS_LOA_DIS_TEST_020100_00001",
+		DSTransID:            TEST_3DS_DS_TRANS_ID,
+		SDKTransID:           req.SDKTransID,
+		TransStatus:          status,
+	}
+}
+
+// =============================================================================
+// Test frictionless ARes with VISA CAVV
+// =============================================================================
+func TestAResVisaCavv(t *testing.T) {
+	req := testAReqBrowser(EMV3DS_VER_220)
+	cavv, _ := hex.DecodeString(TEST_V_RS_CAVV)
+
+	r := testARes(req, EMV3DS_TRANS_STATUS_A)
+	r.ECI = "06"
+	if err := r.SetAuthenticationValue(cavv); err != nil {
+		t.Fatalf("[EMV3DS]: Failed to set authentication value: %s\n", err)
+	}
+	data, err := r.Marshal(req)
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to marshal ARes: %s\n", err)
+	}
+	u, err := UnmarshalARes(data, req)
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to unmarshal ARes: %s\n", err)
+	}
+	d, err := u.VisaCavv()
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to decode VISA CAVV: %s\n", err)
+	}
+	if d.ARC != TEST_V_I_AUTH_RC || d.KeyID != TEST_V_I_CAVV_KEY_ID || d.CVV2 != TEST_V_CVV2 || d.ATN != TEST_V_I_ATN || d.UN != 7993 {
+		t.Fatalf("[EMV3DS]: Invalid VISA CAVV: %+v\n", d)
+	}
+	if _, err := u.MasterCardAAV(); err == nil {
+		t.Fatalf("[EMV3DS]: Decoded VISA CAVV as MasterCard AAV\n")
+	}
+}
+
+// =============================================================================
+// Test frictionless ARes with Master Card AAV
+// =============================================================================
+func TestAResMasterCardAAV(t *testing.T) {
+	req := testAReqApp(EMV3DS_VER_231)
+	aav, _ := hex.DecodeString("8C7CA7FBB6058B511401110000002F3547BA1EFF")
+
+	r := testARes(req, EMV3DS_TRANS_STATUS_Y)
+	r.ECI = "02"
+	r.SetAuthenticationValue(aav)
+	if err := r.Validate(req); err != nil {
+		t.Fatalf("[EMV3DS]: Failed to validate ARes: %s\n", err)
+	}
+	d, err := r.MasterCardAAV()
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to decode MasterCard AAV: %s\n", err)
+	}
+	if d.ControlByte != MC_AAV_CB_AUTHENTICATED || d.ACSID != TEST_MC_ACS_ID || d.AuthMethod != TEST_MC_ACS_AUTH_METHOD ||
+		d.BINKeyID != TEST_MC_BIN_KEY_ID || d.TSN != TEST_MC_TSN || !strings.EqualFold(hex.EncodeToString(d.MerchantNameHash), "7CA7FBB6058B5114") {
+		t.Fatalf("[EMV3DS]: Invalid MasterCard AAV: %+v\n", d)
+	}
+}
+
+// =============================================================================
+// Test ARes validation
+// =============================================================================
+func TestAResValidate(t *testing.T) {
+	req := testAReqApp(EMV3DS_VER_220)
+
+	r := testARes(req, EMV3DS_TRANS_STATUS_Y)
+	r.ECI = "05"
+	if err := r.Validate(req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated ARes without authentication value\n")
+	}
+	r = testARes(req, EMV3DS_TRANS_STATUS_N)
+	if err := r.Validate(req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated ARes without transStatusReason\n")
+	}
+	r.TransStatusReason = "01"
+	r.SDKTransID = TEST_3DS_ACS_TRANS_ID
+	if err := r.Validate(req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated ARes with other sdkTransID\n")
+	}
+	r = testARes(req, EMV3DS_TRANS_STATUS_C)
+	r.ACSChallengeMandated, r.AuthenticationType = "Y", "02"
+	if err := r.Validate(req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated app challenge ARes without acsSignedContent\n")
+	}
+	r.ACSSignedContent = "eyJhbGciOiJQUzI1NiJ9.e30.c2ln"
+	r.ACSRenderingType = &EMV3DSRenderingType{ACSInterface: "01", ACSUITemplate: "01"}
+	if err := r.Validate(req); err != nil {
+		t.Fatalf("[EMV3DS]: Failed to validate challenge ARes: %s\n", err)
+	}
+	req = testAReqBrowser(EMV3DS_VER_210)
+	r = testARes(req, EMV3DS_TRANS_STATUS_D)
+	if err := r.Validate(req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated decoupled ARes for version 2.1.0\n")
+	}
+}
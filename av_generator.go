@@ -0,0 +1,131 @@
+package gocavv
+
+import (
+	"fmt"
+)
+
+// =============================================================================
+//  AuthenticationValueGenerator calculates authentication value (CAVV, AAV,
+//  IAV) of card brand for ACS decision. Issuer keys are selected from
+//  KeyRegistry by brand & key indicator of the decision
+// =============================================================================
+type AuthenticationValueGenerator interface {
+	Brand() CardBrand
+	Generate(pan, merchName string, d *ACSDecision) ([]byte, error)
+}
+
+// =============================================================================
+//  Select authentication value generator for card brand of PAN (default BIN
+//  ranges) and 3-D Secure version (1.0.2 or 2.x.x)
+// =============================================================================
+func NewAuthenticationValueGenerator(pan, version string, keys *KeyRegistry) (AuthenticationValueGenerator, error) {
+	return defaultBINTable.Generator(pan, version, keys)
+}
+
+// =============================================================================
+//  Select authentication value generator for card brand of PAN and 3-D Secure
+//  version (1.0.2 or 2.x.x)
+// =============================================================================
+func (t *BINTable) Generator(pan, version string, keys *KeyRegistry) (AuthenticationValueGenerator, error) {
+	brand := t.Brand(pan)
+	if brand == CARD_BRAND_UNKNOWN {
+		return nil, fmt.Errorf("Unknown card brand of Primary Account Number (PAN)")
+	}
+	return GeneratorForBrand(brand, version, keys)
+}
+
+// =============================================================================
+//  Authentication value generator for card brand and 3-D Secure version:
+//  Master Card values are SPA AAV for 1.0.2 and IAV (SPA2) for EMV 3-D Secure
+//  2.x.x
+// =============================================================================
+func GeneratorForBrand(brand CardBrand, version string, keys *KeyRegistry) (AuthenticationValueGenerator, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("Key registry is required for %s authentication value", brand)
+	}
+	if version != TDS_MSG_VER_1 && !isEMV3DSVersion(version) {
+		return nil, fmt.Errorf("Unsupported 3-D Secure version: %q", version)
+	}
+	spa2 := version != TDS_MSG_VER_1
+
+	switch brand {
+	case CARD_BRAND_VISA:
+		return &VisaGenerator{Keys: keys}, nil
+	case CARD_BRAND_MASTERCARD:
+		return &MasterCardGenerator{Keys: keys, SPA2: spa2}, nil
+	}
+	return nil, fmt.Errorf("No authentication value generator for card brand: %s", brand)
+}
+
+// =============================================================================
+//  Helper function to get Authentication Results Code of decision, values
+//  are calculated for authenticated (Y) & attempts (A) transactions only
+// =============================================================================
+func decisionResultsCode(brand CardBrand, d *ACSDecision) (uint8, error) {
+	if d == nil {
+		return 0, fmt.Errorf("ACS decision is required for %s authentication value", brand)
+	}
+	if d.Status != EMV3DS_TRANS_STATUS_Y && d.Status != EMV3DS_TRANS_STATUS_A {
+		return 0, fmt.Errorf("No %s authentication value for transaction status: %q", brand, d.Status)
+	}
+	return VisaAuthResultsCode(d.Status)
+}
+
+// =============================================================================
+//  VISA CAVV (CVV with ATN), key pair of CAVV Key Indicator
+// =============================================================================
+type VisaGenerator struct {
+	Keys *KeyRegistry
+}
+
+func (g *VisaGenerator) Brand() CardBrand {
+	return CARD_BRAND_VISA
+}
+
+func (g *VisaGenerator) Generate(pan, merchName string, d *ACSDecision) ([]byte, error) {
+	arc, err := decisionResultsCode(CARD_BRAND_VISA, d)
+	if err != nil {
+		return nil, err
+	}
+	p, err := g.Keys.CryptoProvider(CARD_BRAND_VISA, d.CAVVKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return GenerateVisaCavvWithProvider(pan, d.ATN, arc, d.SecondFactor, d.CAVVKeyID, p)
+}
+
+// =============================================================================
+//  Master Card SPA AAV (HMAC-SHA1) or, when SPA2 is set, IAV (HMAC-SHA256),
+//  secret key of BIN Key Identifier. IAV carries no transaction status (see
+//  DeriveECI) so it is generated for authenticated (Y) transactions only
+// =============================================================================
+type MasterCardGenerator struct {
+	Keys *KeyRegistry
+	SPA2 bool
+}
+
+func (g *MasterCardGenerator) Brand() CardBrand {
+	return CARD_BRAND_MASTERCARD
+}
+
+func (g *MasterCardGenerator) Generate(pan, merchName string, d *ACSDecision) ([]byte, error) {
+	if _, err := decisionResultsCode(CARD_BRAND_MASTERCARD, d); err != nil {
+		return nil, err
+	}
+	mp, err := g.Keys.MacProvider(CARD_BRAND_MASTERCARD, d.BINKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if g.SPA2 {
+		if d.Status != EMV3DS_TRANS_STATUS_Y {
+			return nil, fmt.Errorf("No Master Card IAV for transaction status: %q", d.Status)
+		}
+		return GenerateMasterCardIAVWithProvider(pan, merchName, d.Amount, d.Currency, d.DSN, mp)
+	}
+	cb := MC_AAV_CB_ATTEMPTS
+	if d.Status == EMV3DS_TRANS_STATUS_Y {
+		cb = MC_AAV_CB_AUTHENTICATED
+	}
+	return GenerateMasterCardAAVWithProvider(MC_HMAC_SHA1, pan, cb, merchName,
+		d.ACSID, d.AuthMethod, d.BINKeyID, d.TSN, nil, nil, mp, nil)
+}
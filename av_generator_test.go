@@ -0,0 +1,103 @@
+package gocavv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// =============================================================================
+// Test authentication value generators selected from PAN
+// =============================================================================
+func TestAuthenticationValueGenerator(t *testing.T) {
+	mcKey, _ := hex.DecodeString("0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B")
+
+	keys := NewKeyRegistry()
+	keys.AddCryptoProvider(CARD_BRAND_VISA, 1, NewSoftwareCryptoProvider(keyAV, keyBV))
+	keys.AddMacProvider(CARD_BRAND_MASTERCARD, TEST_MC_BIN_KEY_ID, NewSoftwareMacProvider(mcKey))
+
+	d := &ACSDecision{
+		Status:       EMV3DS_TRANS_STATUS_Y,
+		ATN:          TEST_V_I_ATN,
+		SecondFactor: 2,
+		CAVVKeyID:    1,
+		ACSID:        TEST_MC_ACS_ID,
+		AuthMethod:   TEST_MC_ACS_AUTH_METHOD,
+		BINKeyID:     TEST_MC_BIN_KEY_ID,
+		TSN:          TEST_MC_TSN,
+	}
+	for pan, verify := range map[string]func(av []byte) (bool, error){
+		TEST_V_PAN_16: func(av []byte) (bool, error) { return VerifyVisaCavv(TEST_V_PAN_16, av, keyAV, keyBV) },
+		"5432109876543210": func(av []byte) (bool, error) {
+			return VerifyMasterCardAAVWithProvider("5432109876543210", TEST_MC_MERCH_NAME, av, NewSoftwareMacProvider(mcKey))
+		},
+	} {
+		g, err := NewAuthenticationValueGenerator(pan, TDS_MSG_VER_1, keys)
+		if err != nil {
+			t.Fatalf("[GENERATOR]: Failed to select generator for %s: %s\n", pan, err)
+		}
+		av, err := g.Generate(pan, TEST_MC_MERCH_NAME, d)
+		if err != nil {
+			t.Fatalf("[GENERATOR]: Failed to generate %s authentication value: %s\n", g.Brand(), err)
+		}
+		if ok, err := verify(av); err != nil || !ok {
+			t.Fatalf("[GENERATOR]: Failed to verify %s authentication value: %v\n", g.Brand(), err)
+		}
+	}
+
+	// Master Card IAV is selected for EMV 3-D Secure 2.x.x
+	d.Amount, d.Currency, d.DSN = 100.25, 978, 1
+	g, err := NewAuthenticationValueGenerator("5432109876543210", EMV3DS_VER_220, keys)
+	if err != nil {
+		t.Fatalf("[GENERATOR]: Failed to select MasterCard generator for %s: %s\n", EMV3DS_VER_220, err)
+	}
+	iav, err := g.Generate("5432109876543210", TEST_MC_MERCH_NAME, d)
+	if err != nil {
+		t.Fatalf("[GENERATOR]: Failed to generate MasterCard IAV: %s\n", err)
+	}
+	exp, _ := GenerateMasterCardIAV("5432109876543210", TEST_MC_MERCH_NAME, 100.25, 978, 1, mcKey)
+	if !bytes.Equal(iav, exp) {
+		t.Fatalf("[GENERATOR]: Invalid MasterCard IAV: %X\n", iav)
+	}
+
+	// IAV has no transaction status, attempts are rejected in SPA2 and
+	// distinguished by control byte of SPA AAV
+	d.Status = EMV3DS_TRANS_STATUS_A
+	if _, err := g.Generate("5432109876543210", TEST_MC_MERCH_NAME, d); err == nil {
+		t.Fatalf("[GENERATOR]: Generated MasterCard IAV for status A\n")
+	}
+	g, _ = NewAuthenticationValueGenerator("5432109876543210", TDS_MSG_VER_1, keys)
+	aavA, err := g.Generate("5432109876543210", TEST_MC_MERCH_NAME, d)
+	if err != nil {
+		t.Fatalf("[GENERATOR]: Failed to generate MasterCard AAV for status A: %s\n", err)
+	}
+	d.Status = EMV3DS_TRANS_STATUS_Y
+	aavY, _ := g.Generate("5432109876543210", TEST_MC_MERCH_NAME, d)
+	if bytes.Equal(aavA, aavY) {
+		t.Fatalf("[GENERATOR]: Same MasterCard AAV for status Y and A: %X\n", aavA)
+	}
+
+	// No value for not authenticated transaction & unknown key
+	g, _ = NewAuthenticationValueGenerator(TEST_V_PAN_16, EMV3DS_VER_220, keys)
+	d.Status = EMV3DS_TRANS_STATUS_N
+	if _, err := g.Generate(TEST_V_PAN_16, "", d); err == nil {
+		t.Fatalf("[GENERATOR]: Generated authentication value for status N\n")
+	}
+	d.Status, d.CAVVKeyID = EMV3DS_TRANS_STATUS_A, 2
+	if _, err := g.Generate(TEST_V_PAN_16, "", d); err == nil {
+		t.Fatalf("[GENERATOR]: Generated authentication value with unknown key\n")
+	}
+	if _, err := NewAuthenticationValueGenerator(TEST_V_PAN_16, "2.0.0", keys); err == nil {
+		t.Fatalf("[GENERATOR]: Selected generator for unsupported 3-D Secure version\n")
+	}
+
+	// No generator for brands without published authentication value
+	for _, pan := range []string{TEST_AX_PAN_15, TEST_JCB_PAN_16, TEST_DS_PAN_16, TEST_MIR_PAN_16, TEST_UP_PAN_16, "9999999999999999"} {
+		if _, err := NewAuthenticationValueGenerator(pan, EMV3DS_VER_220, keys); err == nil {
+			t.Fatalf("[GENERATOR]: Selected generator for %s\n", pan)
+		}
+	}
+	if _, err := GeneratorForBrand(CARD_BRAND_CARTES_BANCAIRES, EMV3DS_VER_220, keys); err == nil {
+		t.Fatalf("[GENERATOR]: Selected generator for Cartes Bancaires\n")
+	}
+}
@@ -0,0 +1,118 @@
+package gocavv
+
+import (
+	"fmt"
+	"sync"
+)
+
+// =============================================================================
+//  BIN range: PAN prefixes Low - High of equal length (e.g. "2221" - "2720")
+// =============================================================================
+type BINRange struct {
+	Low   string
+	High  string
+	Brand CardBrand
+}
+
+// =============================================================================
+//  Default BIN ranges of international card brands. Cartes Bancaires cards
+//  are co-badged with VISA / Master Card and detected as those, Diners Club
+//  International cards are acquired on Discover network and detected as
+//  Discover
+// =============================================================================
+var defaultBINRanges = []BINRange{
+	{"4", "4", CARD_BRAND_VISA},
+	{"51", "55", CARD_BRAND_MASTERCARD},
+	{"2221", "2720", CARD_BRAND_MASTERCARD},
+	{"2200", "2204", CARD_BRAND_MIR},
+	{"34", "34", CARD_BRAND_AMEX},
+	{"37", "37", CARD_BRAND_AMEX},
+	{"3528", "3589", CARD_BRAND_JCB},
+	{"6011", "6011", CARD_BRAND_DISCOVER},
+	{"644", "649", CARD_BRAND_DISCOVER},
+	{"65", "65", CARD_BRAND_DISCOVER},
+	{"300", "305", CARD_BRAND_DISCOVER},
+	{"36", "36", CARD_BRAND_DISCOVER},
+	{"38", "39", CARD_BRAND_DISCOVER},
+	{"62", "62", CARD_BRAND_UNIONPAY},
+	{"81", "81", CARD_BRAND_UNIONPAY},
+}
+
+// =============================================================================
+//  BINTable detects card brand of PAN, the longest matching range wins so
+//  issuer specific ranges (e.g. 6 or 8 digits) override brand ranges.
+//  Safe for concurrent use
+// =============================================================================
+type BINTable struct {
+	mu     sync.RWMutex
+	ranges []BINRange
+}
+
+var defaultBINTable = NewDefaultBINTable()
+
+// =============================================================================
+//  Create BIN table with given ranges
+// =============================================================================
+func NewBINTable(ranges ...BINRange) (*BINTable, error) {
+	t := &BINTable{}
+	for _, r := range ranges {
+		if err := t.Add(r); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// =============================================================================
+//  Create BIN table with default ranges of international card brands
+// =============================================================================
+func NewDefaultBINTable() *BINTable {
+	return &BINTable{ranges: append([]BINRange(nil), defaultBINRanges...)}
+}
+
+// =============================================================================
+//  Add BIN range to table
+// =============================================================================
+func (t *BINTable) Add(r BINRange) error {
+	if len(r.Low) == 0 || len(r.Low) > 8 || len(r.Low) != len(r.High) || !isDigits(r.Low) || !isDigits(r.High) {
+		return fmt.Errorf("Invalid BIN range: %q - %q", r.Low, r.High)
+	}
+	if r.Low > r.High {
+		return fmt.Errorf("Invalid BIN range: %q more than %q", r.Low, r.High)
+	}
+	if r.Brand == CARD_BRAND_UNKNOWN || r.Brand > CARD_BRAND_CARTES_BANCAIRES {
+		return fmt.Errorf("Unsupported card brand: %s", r.Brand)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ranges = append(t.ranges, r)
+	return nil
+}
+
+// =============================================================================
+//  Detect card brand of PAN, CARD_BRAND_UNKNOWN if no range matches
+// =============================================================================
+func (t *BINTable) Brand(pan string) CardBrand {
+	if len(pan) < 13 || len(pan) > 19 || !isDigits(pan) {
+		return CARD_BRAND_UNKNOWN
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	brand, n := CARD_BRAND_UNKNOWN, 0
+	for _, r := range t.ranges {
+		p := pan[:len(r.Low)]
+		// Later ranges of the same length override earlier ones
+		if len(r.Low) >= n && p >= r.Low && p <= r.High {
+			brand, n = r.Brand, len(r.Low)
+		}
+	}
+	return brand
+}
+
+// =============================================================================
+//  Detect card brand of PAN with default BIN ranges
+// =============================================================================
+func DetectCardBrand(pan string) CardBrand {
+	return defaultBINTable.Brand(pan)
+}
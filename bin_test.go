@@ -0,0 +1,66 @@
+package gocavv
+
+import (
+	"testing"
+)
+
+const (
+	TEST_AX_PAN_15  string = "378282246310005"
+	TEST_JCB_PAN_16 string = "3530111333300000"
+	TEST_MIR_PAN_16 string = "2200770212727079"
+	TEST_DS_PAN_16  string = "6011000990139424"
+	TEST_UP_PAN_16  string = "6212345678901232"
+	TEST_UP_PAN_19  string = "6212345678901234567"
+)
+
+// =============================================================================
+// Test card brand detection by BIN ranges
+// =============================================================================
+func TestBINDetection(t *testing.T) {
+	for pan, brand := range map[string]CardBrand{
+		TEST_V_PAN_16:          CARD_BRAND_VISA,
+		"5432109876543210":     CARD_BRAND_MASTERCARD,
+		"2221000000000009":     CARD_BRAND_MASTERCARD,
+		"2720999999999996":     CARD_BRAND_MASTERCARD,
+		TEST_MIR_PAN_16:        CARD_BRAND_MIR,
+		TEST_AX_PAN_15:         CARD_BRAND_AMEX,
+		TEST_JCB_PAN_16:        CARD_BRAND_JCB,
+		TEST_DS_PAN_16:         CARD_BRAND_DISCOVER,
+		"6445644564456445":     CARD_BRAND_DISCOVER,
+		"30569309025904":       CARD_BRAND_DISCOVER,
+		"36227206271667":       CARD_BRAND_DISCOVER,
+		"38520000023237":       CARD_BRAND_DISCOVER,
+		"30600000000000":       CARD_BRAND_UNKNOWN,
+		TEST_UP_PAN_19:         CARD_BRAND_UNIONPAY,
+		"8171999927660000":     CARD_BRAND_UNIONPAY,
+		"2205000000000000":     CARD_BRAND_UNKNOWN,
+		"2721000000000000":     CARD_BRAND_UNKNOWN,
+		"9999999999999999":     CARD_BRAND_UNKNOWN,
+		"41234567890A2345":     CARD_BRAND_UNKNOWN,
+		"4123456789012":        CARD_BRAND_VISA,
+		"412345678901":         CARD_BRAND_UNKNOWN,
+		"41234567890123456789": CARD_BRAND_UNKNOWN,
+	} {
+		if b := DetectCardBrand(pan); b != brand {
+			t.Fatalf("[BIN]: Invalid card brand of %s: %s, expected: %s\n", pan, b, brand)
+		}
+	}
+
+	// Issuer specific range overrides brand range
+	tbl := NewDefaultBINTable()
+	if err := tbl.Add(BINRange{"497010", "497019", CARD_BRAND_CARTES_BANCAIRES}); err != nil {
+		t.Fatalf("[BIN]: Failed to add BIN range: %s\n", err)
+	}
+	if b := tbl.Brand("4970101122334455"); b != CARD_BRAND_CARTES_BANCAIRES {
+		t.Fatalf("[BIN]: Invalid card brand of configured range: %s\n", b)
+	}
+	if b := DetectCardBrand("4970101122334455"); b != CARD_BRAND_VISA {
+		t.Fatalf("[BIN]: Default table is modified by configured range: %s\n", b)
+	}
+	if _, err := NewBINTable(BINRange{"51", "5", CARD_BRAND_MASTERCARD}); err == nil {
+		t.Fatalf("[BIN]: Created table with invalid BIN range\n")
+	}
+	if err := tbl.Add(BINRange{"55", "51", CARD_BRAND_MASTERCARD}); err == nil {
+		t.Fatalf("[BIN]: Added reversed BIN range\n")
+	}
+}
@@ -0,0 +1,40 @@
+package gocavv
+
+// =============================================================================
+//  Card brand (payment scheme)
+// =============================================================================
+type CardBrand uint8
+
+const (
+	CARD_BRAND_UNKNOWN          CardBrand = 0
+	CARD_BRAND_VISA             CardBrand = 1
+	CARD_BRAND_MASTERCARD       CardBrand = 2
+	CARD_BRAND_AMEX             CardBrand = 3
+	CARD_BRAND_JCB              CardBrand = 4
+	CARD_BRAND_DISCOVER         CardBrand = 5
+	CARD_BRAND_UNIONPAY         CardBrand = 6
+	CARD_BRAND_MIR              CardBrand = 7
+	CARD_BRAND_CARTES_BANCAIRES CardBrand = 8
+)
+
+func (b CardBrand) String() string {
+	switch b {
+	case CARD_BRAND_VISA:
+		return "VISA"
+	case CARD_BRAND_MASTERCARD:
+		return "MasterCard"
+	case CARD_BRAND_AMEX:
+		return "American Express"
+	case CARD_BRAND_JCB:
+		return "JCB"
+	case CARD_BRAND_DISCOVER:
+		return "Discover"
+	case CARD_BRAND_UNIONPAY:
+		return "UnionPay"
+	case CARD_BRAND_MIR:
+		return "Mir"
+	case CARD_BRAND_CARTES_BANCAIRES:
+		return "Cartes Bancaires"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,213 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"encoding/json"
+	"fmt"
+)
+
+/*
+EMV 3-D Secure 2.x app challenge channel protection:
+------------------------------------------------------------------------------------------------------------------------
+|  Step                               |  Description                                                                   |
+------------------------------------------------------------------------------------------------------------------------
+|  AReq sdkEphemPubKey                |  SDK ephemeral P-256 public key (JWK)                                          |
+|  ARes acsSignedContent              |  ACS ephemeral P-256 public key (acsEphemPubKey, JWS signed by ACS)            |
+|  Shared secret Z                    |  ECDH(SDK ephemeral, ACS ephemeral)                                            |
+|  Challenge key                      |  Concat KDF SHA-256: keydatalen 256, AlgorithmID & PartyUInfo empty,           |
+|                                     |  PartyVInfo sdkReferenceNumber                                                 |
+------------------------------------------------------------------------------------------------------------------------
+|  A128CBC-HS256                      |  CReq & CRes: whole 32-byte challenge key                                      |
+|  A128GCM                            |  CReq (SDK -> ACS): leftmost 16 bytes, CRes (ACS -> SDK): rightmost 16 bytes   |
+------------------------------------------------------------------------------------------------------------------------
+CReq & CRes are JWE (alg dir) with kid set to acsTransID
+*/
+
+// =============================================================================
+//  Derive challenge channel key (32 bytes) from own ephemeral private key and
+//  peer ephemeral public key: ACS uses ACS ephemeral key & sdkEphemPubKey,
+//  SDK uses SDK ephemeral key & acsEphemPubKey
+// =============================================================================
+func DeriveChallengeKey(priv crypto.PrivateKey, peer crypto.PublicKey, sdkReferenceNumber string) ([]byte, error) {
+	if sdkReferenceNumber == "" {
+		return nil, fmt.Errorf("SDK reference number is required for challenge key derivation")
+	}
+	k, err := toECDHPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := toECDHPublicKey(peer)
+	if err != nil {
+		return nil, err
+	}
+	z, err := k.ECDH(pk)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(z)
+	return concatKDF(z, 32, nil, nil, []byte(sdkReferenceNumber)), nil
+}
+
+// =============================================================================
+//  Get SDK ephemeral public key (sdkEphemPubKey) of app AReq
+// =============================================================================
+func (r *AReq) SDKEphemeralKey() (*ecdh.PublicKey, error) {
+	if len(r.SDKEphemPubKey) == 0 {
+		return nil, fmt.Errorf("Required EMV 3-D Secure field sdkEphemPubKey is missing")
+	}
+	k := &JWK{}
+	if err := json.Unmarshal(r.SDKEphemPubKey, k); err != nil {
+		return nil, fmt.Errorf("Invalid EMV 3-D Secure field sdkEphemPubKey: %s", err)
+	}
+	return k.ecdhPublicKey()
+}
+
+// =============================================================================
+//  Decrypt sdkEncData of app AReq with DS private key (DS side), returns
+//  device information JSON
+// =============================================================================
+func (r *AReq) DecryptSDKEncData(priv crypto.PrivateKey) ([]byte, error) {
+	if r.SDKEncData == "" {
+		return nil, fmt.Errorf("Required EMV 3-D Secure field sdkEncData is missing")
+	}
+	data, _, err := DecryptJWE(r.SDKEncData, priv)
+	return data, err
+}
+
+// =============================================================================
+//  Challenge channel: key derived by DeriveChallengeKey, content encryption
+//  algorithm & acsTransID (JWE kid)
+// =============================================================================
+type ChallengeChannel struct {
+	Key        []byte
+	Enc        string
+	ACSTransID string
+}
+
+// =============================================================================
+//  Create challenge channel for ACS side from AReq (sdkEphemPubKey &
+//  sdkReferenceNumber) and ACS ephemeral private key
+// =============================================================================
+func NewACSChallengeChannel(areq *AReq, acsTransID string, acsEphemKey crypto.PrivateKey, enc string) (*ChallengeChannel, error) {
+	if _, err := jweKeyLen(enc); err != nil {
+		return nil, err
+	}
+	pk, err := areq.SDKEphemeralKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := DeriveChallengeKey(acsEphemKey, pk, areq.SDKReferenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	return &ChallengeChannel{Key: key, Enc: enc, ACSTransID: acsTransID}, nil
+}
+
+// =============================================================================
+//  Helper function to get direction key: toACS for CReq, CRes otherwise
+// =============================================================================
+func (c *ChallengeChannel) key(toACS bool) ([]byte, error) {
+	if len(c.Key) != 32 {
+		return nil, fmt.Errorf("Invalid challenge key length: %d", len(c.Key))
+	}
+	switch c.Enc {
+	case JWE_ENC_A128CBC_HS256:
+		return c.Key, nil
+	case JWE_ENC_A128GCM:
+		if toACS {
+			return c.Key[:16], nil
+		}
+		return c.Key[16:], nil
+	}
+	return nil, fmt.Errorf("Unsupported JWE content encryption algorithm: %q", c.Enc)
+}
+
+// =============================================================================
+//  Helper function to encrypt challenge message
+// =============================================================================
+func (c *ChallengeChannel) encrypt(v interface{}, toACS bool) (string, error) {
+	key, err := c.key(toACS)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return EncryptJWEDirect(data, key, c.Enc, c.ACSTransID)
+}
+
+// =============================================================================
+//  Helper function to decrypt challenge message, JWE enc & kid must match
+// =============================================================================
+func (c *ChallengeChannel) decrypt(jwe string, toACS bool) ([]byte, error) {
+	key, err := c.key(toACS)
+	if err != nil {
+		return nil, err
+	}
+	data, h, err := DecryptJWEDirect(jwe, key)
+	if err != nil {
+		return nil, err
+	}
+	if h.Enc != c.Enc || h.Kid != c.ACSTransID {
+		return nil, fmt.Errorf("JWE header does not match challenge channel: %s/%q", h.Enc, h.Kid)
+	}
+	return data, nil
+}
+
+// =============================================================================
+//  Validate & encrypt CReq (SDK side)
+// =============================================================================
+func (c *ChallengeChannel) EncryptCReq(r *CReq) (string, error) {
+	if err := r.Validate(EMV3DS_CHANNEL_APP); err != nil {
+		return "", err
+	}
+	return c.encrypt(r, true)
+}
+
+// =============================================================================
+//  Decrypt & validate CReq (ACS side)
+// =============================================================================
+func (c *ChallengeChannel) DecryptCReq(jwe string) (*CReq, error) {
+	data, err := c.decrypt(jwe, true)
+	if err != nil {
+		return nil, err
+	}
+	r, err := UnmarshalCReq(data, EMV3DS_CHANNEL_APP)
+	if err != nil {
+		return nil, err
+	}
+	if r.ACSTransID != c.ACSTransID {
+		return nil, fmt.Errorf("CReq acsTransID %q does not match challenge channel: %q", r.ACSTransID, c.ACSTransID)
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  Validate & encrypt CRes for CReq (ACS side)
+// =============================================================================
+func (c *ChallengeChannel) EncryptCRes(r *CRes, req *CReq) (string, error) {
+	if err := r.Validate(EMV3DS_CHANNEL_APP, req); err != nil {
+		return "", err
+	}
+	return c.encrypt(r, false)
+}
+
+// =============================================================================
+//  Decrypt & validate CRes for CReq (SDK side)
+// =============================================================================
+func (c *ChallengeChannel) DecryptCRes(jwe string, req *CReq) (*CRes, error) {
+	data, err := c.decrypt(jwe, false)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalCRes(data, EMV3DS_CHANNEL_APP, req)
+}
+
+// =============================================================================
+//  Wipe challenge key
+// =============================================================================
+func (c *ChallengeChannel) Zero() {
+	zeroBytes(c.Key)
+}
@@ -0,0 +1,164 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+CReq message EMV 3-D Secure 2.x:
+------------------------------------------------------------------------------------------------------------------------
+|  Field                              |  Required                                              |   Format              |
+------------------------------------------------------------------------------------------------------------------------
+|  messageType, messageVersion        |  always                                                |   CReq, 2.x.x         |
+|  threeDSServerTransID, acsTransID   |  always                                                |   UUID (36 chars)     |
+|  sdkTransID, sdkCounterStoA         |  APP                                                   |   UUID, 3 digits      |
+|  challengeWindowSize                |  BRW                                                   |   01-05               |
+------------------------------------------------------------------------------------------------------------------------
+|  challengeCancel                    |  cardholder / SDK cancelled challenge                  |   01-08               |
+|  challengeDataEntry                 |  text, single & multi select UI (APP)                  |   max 45 chars        |
+|  challengeHTMLDataEntry             |  HTML UI (APP)                                         |   max 256 chars       |
+|  challengeNoEntry                   |  no data entered (2.2.0+)                              |   Y                   |
+|  oobContinue                        |  OOB UI continue button pressed (APP)                  |   boolean             |
+|  resendChallenge                    |  resend challenge information code                     |   Y/N                 |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	// ACS UI type
+	EMV3DS_UI_TEXT          string = "01"
+	EMV3DS_UI_SINGLE_SELECT string = "02"
+	EMV3DS_UI_MULTI_SELECT  string = "03"
+	EMV3DS_UI_OOB           string = "04"
+	EMV3DS_UI_HTML          string = "05"
+
+	// Challenge cancelation indicator
+	EMV3DS_CHALLENGE_CANCEL_CARDHOLDER  string = "01"
+	EMV3DS_CHALLENGE_CANCEL_TIMEOUT     string = "04"
+	EMV3DS_CHALLENGE_CANCEL_ACS_TIMEOUT string = "05"
+	EMV3DS_CHALLENGE_CANCEL_ACS_OTHER   string = "06"
+	EMV3DS_CHALLENGE_CANCEL_UNKNOWN     string = "07"
+	EMV3DS_CHALLENGE_CANCEL_SDK_TIMEOUT string = "08"
+)
+
+// =============================================================================
+//  CReq (Challenge Request) EMV 3-D Secure 2.x
+// =============================================================================
+type CReq struct {
+	MessageType    string `json:"messageType"`
+	MessageVersion string `json:"messageVersion"`
+
+	ThreeDSServerTransID string `json:"threeDSServerTransID"`
+	ACSTransID           string `json:"acsTransID"`
+	SDKTransID           string `json:"sdkTransID,omitempty"`
+	SDKCounterStoA       string `json:"sdkCounterStoA,omitempty"`
+	ChallengeWindowSize  string `json:"challengeWindowSize,omitempty"`
+
+	ChallengeCancel        string `json:"challengeCancel,omitempty"`
+	ChallengeDataEntry     string `json:"challengeDataEntry,omitempty"`
+	ChallengeHTMLDataEntry string `json:"challengeHTMLDataEntry,omitempty"`
+	ChallengeNoEntry       string `json:"challengeNoEntry,omitempty"`
+	OOBContinue            *bool  `json:"oobContinue,omitempty"`
+	ResendChallenge        string `json:"resendChallenge,omitempty"`
+
+	MessageExtension []EMV3DSMessageExtension `json:"messageExtension,omitempty"`
+}
+
+// =============================================================================
+//  Check CReq cancels challenge
+// =============================================================================
+func (r *CReq) IsCancel() bool {
+	return r.ChallengeCancel != ""
+}
+
+// =============================================================================
+//  Validate CReq fields for device channel of authentication
+// =============================================================================
+func (r *CReq) Validate(channel string) error {
+	if err := checkEMV3DSHeader(r.MessageType, "CReq", r.MessageVersion); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("threeDSServerTransID", r.ThreeDSServerTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("acsTransID", r.ACSTransID); err != nil {
+		return err
+	}
+	switch channel {
+	case EMV3DS_CHANNEL_APP:
+		if err := checkEMV3DSTransID("sdkTransID", r.SDKTransID); err != nil {
+			return err
+		}
+		if err := checkEMV3DSDigits("sdkCounterStoA", r.SDKCounterStoA, 3, 3); err != nil {
+			return err
+		}
+	case EMV3DS_CHANNEL_BRW:
+		if err := checkEMV3DSEnum("challengeWindowSize", r.ChallengeWindowSize, "01", "02", "03", "04", "05"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Invalid EMV 3-D Secure challenge device channel: %q", channel)
+	}
+
+	if r.ChallengeCancel != "" {
+		if err := checkEMV3DSEnum("challengeCancel", r.ChallengeCancel, "01", "04", "05", "06", "07", "08"); err != nil {
+			return err
+		}
+		if r.MessageVersion == EMV3DS_VER_210 && r.ChallengeCancel > EMV3DS_CHALLENGE_CANCEL_ACS_OTHER {
+			return fmt.Errorf("Invalid EMV 3-D Secure field challengeCancel %s for version %s", r.ChallengeCancel, r.MessageVersion)
+		}
+	}
+	if err := checkEMV3DSOptional("challengeDataEntry", r.ChallengeDataEntry, 1, 45); err != nil {
+		return err
+	}
+	if err := checkEMV3DSOptional("challengeHTMLDataEntry", r.ChallengeHTMLDataEntry, 1, 256); err != nil {
+		return err
+	}
+	if r.ChallengeNoEntry != "" {
+		if r.MessageVersion == EMV3DS_VER_210 || r.ChallengeNoEntry != "Y" {
+			return fmt.Errorf("Invalid EMV 3-D Secure field challengeNoEntry: %q", r.ChallengeNoEntry)
+		}
+	}
+	if r.ResendChallenge != "" {
+		if err := checkEMV3DSEnum("resendChallenge", r.ResendChallenge, "Y", "N"); err != nil {
+			return err
+		}
+	}
+
+	// Only one cardholder action per CReq
+	n := 0
+	for _, set := range []bool{r.ChallengeCancel != "", r.ChallengeDataEntry != "", r.ChallengeHTMLDataEntry != "",
+		r.ChallengeNoEntry != "", r.OOBContinue != nil, r.ResendChallenge == "Y"} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("EMV 3-D Secure CReq contains more than one cardholder action")
+	}
+	return validateEMV3DSExtensions(r.MessageExtension)
+}
+
+// =============================================================================
+//  Validate & marshal CReq to JSON
+// =============================================================================
+func (r *CReq) Marshal(channel string) ([]byte, error) {
+	if err := r.Validate(channel); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// =============================================================================
+//  Parse & validate CReq JSON message for device channel
+// =============================================================================
+func UnmarshalCReq(data []byte, channel string) (*CReq, error) {
+	r := &CReq{}
+	if err := unmarshalEMV3DSMessage(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(channel); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
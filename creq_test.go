@@ -0,0 +1,55 @@
+package gocavv
+
+import (
+	"testing"
+)
+
+// =============================================================================
+//  Helper function to create app CReq
+// =============================================================================
+func testCReqApp() *CReq {
+	return &CReq{
+		MessageType:          "CReq",
+		MessageVersion:       EMV3DS_VER_220,
+		ThreeDSServerTransID: TEST_3DS_SERVER_TRANS_ID,
+		ACSTransID:           TEST_3DS_ACS_TRANS_ID,
+		SDKTransID:           TEST_3DS_SDK_TRANS_ID,
+		SDKCounterStoA:       "001",
+	}
+}
+
+// =============================================================================
+// Test CReq marshalling & validation
+// =============================================================================
+func TestCReqValidate(t *testing.T) {
+	r := testCReqApp()
+	r.ChallengeDataEntry = "123456"
+	data, err := r.Marshal(EMV3DS_CHANNEL_APP)
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to marshal CReq: %s\n", err)
+	}
+	u, err := UnmarshalCReq(data, EMV3DS_CHANNEL_APP)
+	if err != nil || u.ChallengeDataEntry != "123456" || u.IsCancel() {
+		t.Fatalf("[EMV3DS]: Failed to unmarshal CReq: %v\n", err)
+	}
+	// Browser channel requires challenge window size
+	if _, err := UnmarshalCReq(data, EMV3DS_CHANNEL_BRW); err == nil {
+		t.Fatalf("[EMV3DS]: Validated browser CReq without challengeWindowSize\n")
+	}
+	// More than one cardholder action
+	r.ChallengeCancel = EMV3DS_CHALLENGE_CANCEL_CARDHOLDER
+	if err := r.Validate(EMV3DS_CHANNEL_APP); err == nil {
+		t.Fatalf("[EMV3DS]: Validated CReq with data entry & cancel\n")
+	}
+	// Cancel indicator 08 is not defined for 2.1.0
+	r = testCReqApp()
+	r.MessageVersion, r.ChallengeCancel = EMV3DS_VER_210, EMV3DS_CHALLENGE_CANCEL_SDK_TIMEOUT
+	if err := r.Validate(EMV3DS_CHANNEL_APP); err == nil {
+		t.Fatalf("[EMV3DS]: Validated 2.1.0 CReq with cancel indicator 08\n")
+	}
+	r = testCReqApp()
+	r.SDKCounterStoA = ""
+	if err := r.Validate(EMV3DS_CHANNEL_APP); err == nil {
+		t.Fatalf("[EMV3DS]: Validated app CReq without sdkCounterStoA\n")
+	}
+}
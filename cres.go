@@ -0,0 +1,264 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+/*
+CRes message EMV 3-D Secure 2.x:
+------------------------------------------------------------------------------------------------------------------------
+|  Field                              |  Required                                              |   Format              |
+------------------------------------------------------------------------------------------------------------------------
+|  messageType, messageVersion        |  always                                                |   CRes, 2.x.x         |
+|  threeDSServerTransID, acsTransID   |  always                                                |   UUID (36 chars)     |
+|  challengeCompletionInd             |  always                                                |   Y/N                 |
+|  sdkTransID, acsCounterAtoS         |  APP                                                   |   UUID, 3 digits      |
+|  transStatus                        |  challenge completed (Y)                               |   Y N U A R           |
+|  acsUiType                          |  challenge not completed (N)                           |   01-05               |
+------------------------------------------------------------------------------------------------------------------------
+|  challengeInfoText                  |  UI 01-04 (APP native)                                 |   max 350 chars       |
+|  submitAuthenticationLabel          |  UI 01-03 (APP native)                                 |   max 45 chars        |
+|  challengeSelectInfo                |  UI 02-03 (APP native)                                 |   name/value pairs    |
+|  oobContinueLabel                   |  UI 04 (APP native)                                    |   max 45 chars        |
+|  acsHTML                            |  UI 05 (APP HTML, BRW)                                 |   base64url HTML      |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+// =============================================================================
+//  Issuer / payment system image URLs by density
+// =============================================================================
+type EMV3DSImage struct {
+	Medium    string `json:"medium,omitempty"`
+	High      string `json:"high,omitempty"`
+	ExtraHigh string `json:"extraHigh,omitempty"`
+}
+
+// =============================================================================
+//  CRes (Challenge Response) EMV 3-D Secure 2.x
+// =============================================================================
+type CRes struct {
+	MessageType    string `json:"messageType"`
+	MessageVersion string `json:"messageVersion"`
+
+	ThreeDSServerTransID string `json:"threeDSServerTransID"`
+	ACSTransID           string `json:"acsTransID"`
+	SDKTransID           string `json:"sdkTransID,omitempty"`
+	ACSCounterAtoS       string `json:"acsCounterAtoS,omitempty"`
+
+	ChallengeCompletionInd string `json:"challengeCompletionInd"`
+	TransStatus            string `json:"transStatus,omitempty"`
+	ACSUIType              string `json:"acsUiType,omitempty"`
+
+	// UI data
+	ACSHTML                    string              `json:"acsHTML,omitempty"`
+	ACSHTMLRefresh             string              `json:"acsHTMLRefresh,omitempty"`
+	ChallengeInfoHeader        string              `json:"challengeInfoHeader,omitempty"`
+	ChallengeInfoLabel         string              `json:"challengeInfoLabel,omitempty"`
+	ChallengeInfoText          string              `json:"challengeInfoText,omitempty"`
+	ChallengeInfoTextIndicator string              `json:"challengeInfoTextIndicator,omitempty"`
+	ChallengeSelectInfo        []map[string]string `json:"challengeSelectInfo,omitempty"`
+	ExpandInfoLabel            string              `json:"expandInfoLabel,omitempty"`
+	ExpandInfoText             string              `json:"expandInfoText,omitempty"`
+	IssuerImage                *EMV3DSImage        `json:"issuerImage,omitempty"`
+	PSImage                    *EMV3DSImage        `json:"psImage,omitempty"`
+	OOBAppURL                  string              `json:"oobAppURL,omitempty"`
+	OOBAppLabel                string              `json:"oobAppLabel,omitempty"`
+	OOBContinueLabel           string              `json:"oobContinueLabel,omitempty"`
+	ResendInformationLabel     string              `json:"resendInformationLabel,omitempty"`
+	SubmitAuthenticationLabel  string              `json:"submitAuthenticationLabel,omitempty"`
+	WhyInfoLabel               string              `json:"whyInfoLabel,omitempty"`
+	WhyInfoText                string              `json:"whyInfoText,omitempty"`
+
+	MessageExtension []EMV3DSMessageExtension `json:"messageExtension,omitempty"`
+}
+
+// =============================================================================
+//  Check challenge is completed
+// =============================================================================
+func (r *CRes) IsCompleted() bool {
+	return r.ChallengeCompletionInd == "Y"
+}
+
+// =============================================================================
+//  Validate CRes fields for device channel of authentication, CReq (may be
+//  nil) transaction ids must be echoed
+// =============================================================================
+func (r *CRes) Validate(channel string, req *CReq) error {
+	if err := checkEMV3DSHeader(r.MessageType, "CRes", r.MessageVersion); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("threeDSServerTransID", r.ThreeDSServerTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("acsTransID", r.ACSTransID); err != nil {
+		return err
+	}
+	if req != nil {
+		if r.ThreeDSServerTransID != req.ThreeDSServerTransID || r.ACSTransID != req.ACSTransID || r.SDKTransID != req.SDKTransID {
+			return fmt.Errorf("CRes transaction ids do not match CReq")
+		}
+	}
+	if channel == EMV3DS_CHANNEL_APP {
+		if err := checkEMV3DSTransID("sdkTransID", r.SDKTransID); err != nil {
+			return err
+		}
+		if err := checkEMV3DSDigits("acsCounterAtoS", r.ACSCounterAtoS, 3, 3); err != nil {
+			return err
+		}
+	}
+	if err := checkEMV3DSEnum("challengeCompletionInd", r.ChallengeCompletionInd, "Y", "N"); err != nil {
+		return err
+	}
+
+	if r.IsCompleted() {
+		if err := checkEMV3DSEnum("transStatus", r.TransStatus,
+			EMV3DS_TRANS_STATUS_Y, EMV3DS_TRANS_STATUS_N, EMV3DS_TRANS_STATUS_U, EMV3DS_TRANS_STATUS_A, EMV3DS_TRANS_STATUS_R); err != nil {
+			return err
+		}
+		return validateEMV3DSExtensions(r.MessageExtension)
+	}
+	if r.TransStatus != "" {
+		return fmt.Errorf("EMV 3-D Secure CRes with challenge not completed must not contain transStatus")
+	}
+	if err := checkEMV3DSEnum("acsUiType", r.ACSUIType,
+		EMV3DS_UI_TEXT, EMV3DS_UI_SINGLE_SELECT, EMV3DS_UI_MULTI_SELECT, EMV3DS_UI_OOB, EMV3DS_UI_HTML); err != nil {
+		return err
+	}
+	if r.ACSUIType == EMV3DS_UI_HTML {
+		if err := checkEMV3DSField("acsHTML", r.ACSHTML, 1, 100000); err != nil {
+			return err
+		}
+		return validateEMV3DSExtensions(r.MessageExtension)
+	}
+	if channel != EMV3DS_CHANNEL_APP {
+		return fmt.Errorf("Invalid EMV 3-D Secure acsUiType %s for browser channel", r.ACSUIType)
+	}
+
+	// Native UI
+	if err := checkEMV3DSField("challengeInfoText", r.ChallengeInfoText, 1, 350); err != nil {
+		return err
+	}
+	switch r.ACSUIType {
+	case EMV3DS_UI_SINGLE_SELECT, EMV3DS_UI_MULTI_SELECT:
+		if len(r.ChallengeSelectInfo) == 0 {
+			return fmt.Errorf("Required EMV 3-D Secure field challengeSelectInfo is missing")
+		}
+		fallthrough
+	case EMV3DS_UI_TEXT:
+		if err := checkEMV3DSField("submitAuthenticationLabel", r.SubmitAuthenticationLabel, 1, 45); err != nil {
+			return err
+		}
+	case EMV3DS_UI_OOB:
+		if err := checkEMV3DSField("oobContinueLabel", r.OOBContinueLabel, 1, 45); err != nil {
+			return err
+		}
+	}
+	return validateEMV3DSExtensions(r.MessageExtension)
+}
+
+// =============================================================================
+//  Validate & marshal CRes to JSON
+// =============================================================================
+func (r *CRes) Marshal(channel string, req *CReq) ([]byte, error) {
+	if err := r.Validate(channel, req); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// =============================================================================
+//  Parse & validate CRes JSON message for device channel & CReq (may be nil)
+// =============================================================================
+func UnmarshalCRes(data []byte, channel string, req *CReq) (*CRes, error) {
+	r := &CRes{}
+	if err := unmarshalEMV3DSMessage(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(channel, req); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  VISA Second Factor Authentication Code (Table D-3) for challenge UI type,
+//  used when the ACS does not know the exact authentication method
+// =============================================================================
+func VisaSecondFactorCode(uiType string) uint8 {
+	switch uiType {
+	case EMV3DS_UI_OOB:
+		return 9 /* OOB with any other method */
+	}
+	return 10 /* Any other authentication method */
+}
+
+// =============================================================================
+//  Completed challenge: AReq of authentication and final CRes, input of the
+//  authentication value calculation. SecondFactor is VISA Table D-3 code,
+//  derived from UIType when not set
+// =============================================================================
+type ChallengeCompletion struct {
+	AReq         *AReq
+	CRes         *CRes
+	UIType       string
+	SecondFactor uint8
+}
+
+// =============================================================================
+//  Helper function to check challenge completed with authentication value
+// =============================================================================
+func (c *ChallengeCompletion) check() error {
+	if c.AReq == nil || c.CRes == nil {
+		return fmt.Errorf("Challenge AReq & CRes are required")
+	}
+	if !c.CRes.IsCompleted() {
+		return fmt.Errorf("Challenge is not completed")
+	}
+	if c.CRes.ThreeDSServerTransID != c.AReq.ThreeDSServerTransID {
+		return fmt.Errorf("CRes threeDSServerTransID %q does not match AReq: %q", c.CRes.ThreeDSServerTransID, c.AReq.ThreeDSServerTransID)
+	}
+	if c.CRes.TransStatus != EMV3DS_TRANS_STATUS_Y && c.CRes.TransStatus != EMV3DS_TRANS_STATUS_A {
+		return fmt.Errorf("Challenge with transStatus %s has no authentication value", c.CRes.TransStatus)
+	}
+	return nil
+}
+
+// =============================================================================
+//  Generate VISA CAVV for completed challenge (ATN & key indicator assigned
+//  by ACS)
+// =============================================================================
+func (c *ChallengeCompletion) GenerateVisaCavv(atn uint, keyID uint8, p CryptoProvider) ([]byte, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	arc, err := VisaAuthResultsCode(c.CRes.TransStatus)
+	if err != nil {
+		return nil, err
+	}
+	sf := c.SecondFactor
+	if sf == 0 {
+		sf = VisaSecondFactorCode(c.UIType)
+	}
+	return GenerateVisaCavvWithProvider(c.AReq.AcctNumber, atn, arc, sf, keyID, p)
+}
+
+// =============================================================================
+//  Generate Master Card IAV for completed challenge (DS sequence number
+//  assigned by ACS)
+// =============================================================================
+func (c *ChallengeCompletion) GenerateMasterCardIAV(dsn uint32, mp MacProvider) ([]byte, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	amount, err := strconv.ParseUint(c.AReq.PurchaseAmount, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid AReq purchaseAmount: %q", c.AReq.PurchaseAmount)
+	}
+	currency, err := strconv.ParseUint(c.AReq.PurchaseCurrency, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid AReq purchaseCurrency: %q", c.AReq.PurchaseCurrency)
+	}
+	return GenerateMasterCardIAVWithProvider(c.AReq.AcctNumber, c.AReq.MerchantName, float64(amount), uint16(currency), dsn, mp)
+}
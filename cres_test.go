@@ -0,0 +1,109 @@
+package gocavv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// =============================================================================
+//  Helper function to create app CRes for CReq
+// =============================================================================
+func testCRes(req *CReq, completion, status string) *CRes {
+	return &CRes{
+		MessageType:            "CRes",
+		MessageVersion:         req.MessageVersion,
+		ThreeDSServerTransID:   req.ThreeDSServerTransID,
+		ACSTransID:             req.ACSTransID,
+		SDKTransID:             req.SDKTransID,
+		ACSCounterAtoS:         "001",
+		ChallengeCompletionInd: completion,
+		TransStatus:            status,
+	}
+}
+
+// =============================================================================
+// Test CRes UI type validation
+// =============================================================================
+func TestCResValidate(t *testing.T) {
+	req := testCReqApp()
+
+	r := testCRes(req, "N", "")
+	r.ACSUIType = EMV3DS_UI_SINGLE_SELECT
+	r.ChallengeInfoText = "Select phone number to receive code"
+	r.SubmitAuthenticationLabel = "Next"
+	if err := r.Validate(EMV3DS_CHANNEL_APP, req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated single select CRes without challengeSelectInfo\n")
+	}
+	r.ChallengeSelectInfo = []map[string]string{{"phone1": "**** 1234"}, {"phone2": "**** 5678"}}
+	data, err := r.Marshal(EMV3DS_CHANNEL_APP, req)
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to marshal CRes: %s\n", err)
+	}
+	u, err := UnmarshalCRes(data, EMV3DS_CHANNEL_APP, req)
+	if err != nil || len(u.ChallengeSelectInfo) != 2 || u.IsCompleted() {
+		t.Fatalf("[EMV3DS]: Failed to unmarshal CRes: %v\n", err)
+	}
+
+	r = testCRes(req, "N", "")
+	r.ACSUIType, r.ChallengeInfoText = EMV3DS_UI_OOB, "Confirm payment in your bank app"
+	if err := r.Validate(EMV3DS_CHANNEL_APP, req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated OOB CRes without oobContinueLabel\n")
+	}
+	// Native UI is not allowed for browser
+	r.OOBContinueLabel = "Continue"
+	if err := r.Validate(EMV3DS_CHANNEL_BRW, nil); err == nil {
+		t.Fatalf("[EMV3DS]: Validated browser CRes with native UI\n")
+	}
+	r = testCRes(req, "Y", "")
+	if err := r.Validate(EMV3DS_CHANNEL_APP, req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated completed CRes without transStatus\n")
+	}
+	r = testCRes(req, "N", EMV3DS_TRANS_STATUS_Y)
+	r.ACSUIType, r.ACSHTML = EMV3DS_UI_HTML, "PGh0bWw-PC9odG1sPg"
+	if err := r.Validate(EMV3DS_CHANNEL_APP, req); err == nil {
+		t.Fatalf("[EMV3DS]: Validated not completed CRes with transStatus\n")
+	}
+}
+
+// =============================================================================
+// Test authentication value generation for completed challenge
+// =============================================================================
+func TestChallengeCompletion(t *testing.T) {
+	areq := testAReqApp(EMV3DS_VER_220)
+	creq := testCReqApp()
+
+	c := &ChallengeCompletion{AReq: areq, CRes: testCRes(creq, "N", ""), UIType: EMV3DS_UI_TEXT}
+	if _, err := c.GenerateVisaCavv(TEST_V_I_ATN, TEST_V_I_CAVV_KEY_ID, NewSoftwareCryptoProvider(keyAV, keyBV)); err == nil {
+		t.Fatalf("[EMV3DS]: Generated CAVV for not completed challenge\n")
+	}
+
+	c.CRes = testCRes(creq, "Y", EMV3DS_TRANS_STATUS_Y)
+	cavv, err := c.GenerateVisaCavv(TEST_V_I_ATN, TEST_V_I_CAVV_KEY_ID, NewSoftwareCryptoProvider(keyAV, keyBV))
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to generate CAVV: %s\n", err)
+	}
+	d, _ := ParseVisaCavv(cavv)
+	if d.ARC != VISA_ARC_AUTHENTICATED || d.SecondFactor != 10 || d.ATN != TEST_V_I_ATN {
+		t.Fatalf("[EMV3DS]: Invalid challenge CAVV: %+v\n", d)
+	}
+	if ok, err := VerifyVisaCavv(areq.AcctNumber, cavv, keyAV, keyBV); err != nil || !ok {
+		t.Fatalf("[EMV3DS]: Failed to verify challenge CAVV: %v\n", err)
+	}
+
+	// Master Card IAV with AReq purchase data
+	secret, _ := hex.DecodeString("B039878C1F96D212F509B2DC4CC8CD1B")
+	iav, err := c.GenerateMasterCardIAV(0x2C1C0497, NewSoftwareMacProvider(secret))
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to generate IAV: %s\n", err)
+	}
+	exp, _ := GenerateMasterCardIAV(areq.AcctNumber, areq.MerchantName, 100, 840, 0x2C1C0497, secret)
+	if !bytes.Equal(iav, exp) {
+		t.Fatalf("[EMV3DS]: Invalid challenge IAV: %X\n", iav)
+	}
+
+	c.CRes = testCRes(creq, "Y", EMV3DS_TRANS_STATUS_N)
+	if _, err := c.GenerateMasterCardIAV(0x2C1C0497, NewSoftwareMacProvider(secret)); err == nil {
+		t.Fatalf("[EMV3DS]: Generated IAV for failed challenge\n")
+	}
+}
@@ -0,0 +1,95 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/subtle"
+	"fmt"
+)
+
+// =============================================================================
+//  CryptoProvider abstracts where the card verification keys live (software,
+//  HSM, ...) and performs the CVV2 style operation used by the CAVV and the
+//  Master Card CVC2 based AAV.
+//
+//  pan   - Primary Account Number (13-19 digits)
+//  atn   - four least significant digits of the ATN (or expiry date)
+//  scode - service code (3 digits)
+// =============================================================================
+type CryptoProvider interface {
+	GenerateCVV2(pan, atn, scode string) (int, error)
+	VerifyCVV2(pan, atn, scode string, cvv2 int) (bool, error)
+}
+
+// =============================================================================
+//  SoftwareCryptoProvider uses clear keys held in process memory
+// =============================================================================
+type SoftwareCryptoProvider struct {
+	keyA, keyB KeyBytes
+	owner      *SecretKey /* Key owning keyA & keyB memory, nil if not owned */
+}
+
+// =============================================================================
+//  Create software crypto provider from clear key A & key B
+// =============================================================================
+func NewSoftwareCryptoProvider(keyA, keyB []byte) *SoftwareCryptoProvider {
+	return &SoftwareCryptoProvider{keyA: keyA, keyB: keyB}
+}
+
+func (p *SoftwareCryptoProvider) GenerateCVV2(pan, atn, scode string) (int, error) {
+	if p.owner != nil && p.owner.Destroyed() {
+		return 0, fmt.Errorf("Key is destroyed")
+	}
+	return generateCVV2(pan, atn, scode, p.keyA, p.keyB)
+}
+
+func (p *SoftwareCryptoProvider) VerifyCVV2(pan, atn, scode string, cvv2 int) (bool, error) {
+	c, err := p.GenerateCVV2(pan, atn, scode)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeEq(int32(c), int32(cvv2)) == 1, nil
+}
+
+func (p SoftwareCryptoProvider) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, "SoftwareCryptoProvider{"+KEY_REDACTED+"}")
+}
+
+// =============================================================================
+//  MacProvider calculates HMAC over MAC input data with issuer secret key
+//  (Master Card AAV HMAC-SHA1 & IAV HMAC-SHA256)
+// =============================================================================
+type MacProvider interface {
+	HMAC(h crypto.Hash, data []byte) ([]byte, error)
+}
+
+// =============================================================================
+//  SoftwareMacProvider uses clear secret key held in process memory
+// =============================================================================
+type SoftwareMacProvider struct {
+	key   KeyBytes
+	owner *SecretKey /* Key owning key memory, nil if not owned */
+}
+
+// =============================================================================
+//  Create software MAC provider from clear secret key
+// =============================================================================
+func NewSoftwareMacProvider(key []byte) *SoftwareMacProvider {
+	return &SoftwareMacProvider{key: key}
+}
+
+func (p *SoftwareMacProvider) HMAC(h crypto.Hash, data []byte) ([]byte, error) {
+	if p.owner != nil && p.owner.Destroyed() {
+		return nil, fmt.Errorf("Key is destroyed")
+	}
+	if !h.Available() {
+		return nil, fmt.Errorf("Unsupported HMAC hash function: %d", h)
+	}
+	m := hmac.New(h.New, p.key)
+	m.Write(data)
+	return m.Sum(nil), nil
+}
+
+func (p SoftwareMacProvider) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, "SoftwareMacProvider{"+KEY_REDACTED+"}")
+}
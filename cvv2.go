@@ -1,6 +1,7 @@
 package gocavv
 
 import (
+	"crypto/cipher"
 	"encoding/hex"
 	"fmt"
 	"regexp"
@@ -12,6 +13,25 @@ import (
 // =============================================================================
 func generateCVV2(pan, atn, scode string, keyA, keyB []byte) (int, error) {
 
+	// Create cipher from keyA
+	cipherA, err := createKeyCipher(keyA)
+	if err != nil {
+		return 0, err
+	}
+	// Create cipher from keyB
+	cipherB, err := createKeyCipher(keyB)
+	if err != nil {
+		return 0, err
+	}
+
+	return generateCVV2Block(pan, atn, scode, cipherA, cipherB)
+}
+// =============================================================================
+//  Helper function to generate CVC2 with key A & key B ciphers
+//  (software or key handles kept in HSM)
+// =============================================================================
+func generateCVV2Block(pan, atn, scode string, cipherA, cipherB cipher.Block) (int, error) {
+
 	var cvv2 string
 
 	// Get PAN length
@@ -29,17 +49,6 @@ func generateCVV2(pan, atn, scode string, keyA, keyB []byte) (int, error) {
 		return 0, fmt.Errorf("Invalid Service Code length: %d, expected: 3", len(scode))
 	}
 
-	// Create cipher from keyA
-	cipherA, err := createKeyCipher(keyA)
-	if err != nil {
-		return 0, err
-	}
-	// Create cipher from keyB
-	cipherB, err := createKeyCipher(keyB)
-	if err != nil {
-		return 0, err
-	}
-
 	if plen > 16 {
 		pan = pan[len(pan)-16:]
 	} else if plen < 16 {
@@ -52,6 +61,8 @@ func generateCVV2(pan, atn, scode string, keyA, keyB []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	// Wipe input field on return
+	defer zeroBytes(src)
 
 	// Split field into two 64-bit blocks
 	block1 := src[:8]
@@ -59,6 +70,7 @@ func generateCVV2(pan, atn, scode string, keyA, keyB []byte) (int, error) {
 
 	// create temporary destination buffer
 	encBlock1 := make([]byte, 8)
+	defer zeroBytes(encBlock1)
 	// Step 4: Using DES, encrypt Block 1 using Key A
 	cipherA.Encrypt(encBlock1, block1)
 	// Step 5: XOR the result of Step 4 with Block 2, then encrypt the XOR result with Key A
@@ -0,0 +1,136 @@
+package gocavv
+
+import (
+	"fmt"
+)
+
+/*
+Electronic Commerce Indicator by card brand & transaction status (3-D Secure 1.0.2 & EMV 3-D Secure 2.x):
+------------------------------------------------------------------------------------------------------------------------
+|  transStatus                        |  MasterCard     |  VISA, Amex, JCB, Discover, UnionPay, Mir, Cartes Bancaires  |
+------------------------------------------------------------------------------------------------------------------------
+|  Y  authenticated                   |  02 (AAV 0x8C)  |  05 (CAVV ARC 0)                                             |
+|  A  attempts                        |  01 (AAV 0x86)  |  06 (CAVV ARC 7)                                             |
+|  N, U, R  not authenticated         |  00             |  07                                                          |
+|  I  informational (2.2.0+ only)     |  06             |  07                                                          |
+|  C, D  challenge / decoupled        |  no ECI, final status is sent in RReq                                          |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	ECI_VISA_AUTHENTICATED     string = "05"
+	ECI_VISA_ATTEMPTS          string = "06"
+	ECI_VISA_NOT_AUTHENTICATED string = "07"
+
+	ECI_MC_AUTHENTICATED     string = "02"
+	ECI_MC_ATTEMPTS          string = "01"
+	ECI_MC_NOT_AUTHENTICATED string = "00"
+	ECI_MC_EXEMPTION         string = "06"
+)
+
+// =============================================================================
+//  Derive ECI for card brand, 3-D Secure version (1.0.2 or 2.x.x) and
+//  transaction status
+// =============================================================================
+func DeriveECI(brand CardBrand, version, status string) (string, error) {
+	if version != TDS_MSG_VER_1 && !isEMV3DSVersion(version) {
+		return "", fmt.Errorf("Unsupported 3-D Secure version: %q", version)
+	}
+	if brand == CARD_BRAND_UNKNOWN || brand > CARD_BRAND_CARTES_BANCAIRES {
+		return "", fmt.Errorf("Unsupported card brand: %s", brand)
+	}
+	mc := brand == CARD_BRAND_MASTERCARD
+
+	switch status {
+	case EMV3DS_TRANS_STATUS_Y:
+		if mc {
+			return ECI_MC_AUTHENTICATED, nil
+		}
+		return ECI_VISA_AUTHENTICATED, nil
+	case EMV3DS_TRANS_STATUS_A:
+		if mc {
+			return ECI_MC_ATTEMPTS, nil
+		}
+		return ECI_VISA_ATTEMPTS, nil
+	case EMV3DS_TRANS_STATUS_N, EMV3DS_TRANS_STATUS_U:
+	case EMV3DS_TRANS_STATUS_R:
+		if version == TDS_MSG_VER_1 {
+			return "", fmt.Errorf("Invalid transaction status %s for 3-D Secure version %s", status, version)
+		}
+	case EMV3DS_TRANS_STATUS_I:
+		if version == TDS_MSG_VER_1 || version == EMV3DS_VER_210 {
+			return "", fmt.Errorf("Invalid transaction status %s for 3-D Secure version %s", status, version)
+		}
+		if mc {
+			return ECI_MC_EXEMPTION, nil
+		}
+	case EMV3DS_TRANS_STATUS_C, EMV3DS_TRANS_STATUS_D:
+		return "", fmt.Errorf("Transaction status %s is not final, no ECI", status)
+	default:
+		return "", fmt.Errorf("Invalid transaction status: %q", status)
+	}
+	if mc {
+		return ECI_MC_NOT_AUTHENTICATED, nil
+	}
+	return ECI_VISA_NOT_AUTHENTICATED, nil
+}
+
+// =============================================================================
+//  Check ECI matches card brand, 3-D Secure version and transaction status
+// =============================================================================
+func CheckECI(brand CardBrand, version, status, eci string) error {
+	exp, err := DeriveECI(brand, version, status)
+	if err != nil {
+		return err
+	}
+	if eci != exp {
+		return fmt.Errorf("Invalid %s ECI for status %s: %s, expected: %s", brand, status, eci, exp)
+	}
+	return nil
+}
+
+// =============================================================================
+//  VISA: ECI for CAVV Authentication Results Code (Table D-2)
+// =============================================================================
+func VisaECIForARC(arc uint8) (string, error) {
+	switch arc {
+	case VISA_ARC_AUTHENTICATED:
+		return ECI_VISA_AUTHENTICATED, nil
+	case VISA_ARC_ATTEMPTS:
+		return ECI_VISA_ATTEMPTS, nil
+	case VISA_ARC_NOT_PERFORMED, VISA_ARC_FAILED:
+		return ECI_VISA_NOT_AUTHENTICATED, nil
+	}
+	return "", fmt.Errorf("Invalid VISA Authentication Results Code: %d", arc)
+}
+
+// =============================================================================
+//  Master Card: ECI for SPA AAV control byte. IAV (SPA2) control byte is the
+//  same for every status, ECI of IAV is derived from transaction status of
+//  ARes / RReq (DeriveECI)
+// =============================================================================
+func MasterCardECIForControlByte(cb uint8) (string, error) {
+	switch cb {
+	case MC_AAV_CB_AUTHENTICATED:
+		return ECI_MC_AUTHENTICATED, nil
+	case MC_AAV_CB_ATTEMPTS:
+		return ECI_MC_ATTEMPTS, nil
+	case MC_IAV_CB:
+		return "", fmt.Errorf("IAV control byte 0x%02X carries no transaction status: use DeriveECI with status of ARes / RReq", cb)
+	}
+	return "", fmt.Errorf("Invalid AAV control byte: 0x%02X", cb)
+}
+
+// =============================================================================
+//  ECI of decoded VISA CAVV
+// =============================================================================
+func (d *VisaCavvData) ECI() (string, error) {
+	return VisaECIForARC(d.ARC)
+}
+
+// =============================================================================
+//  ECI of decoded Master Card SPA AAV
+// =============================================================================
+func (d *MasterCardAAVData) ECI() (string, error) {
+	return MasterCardECIForControlByte(d.ControlByte)
+}
@@ -0,0 +1,106 @@
+package gocavv
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Test ECI derivation for card brand, version & transaction status
+// =============================================================================
+func TestECIDerive(t *testing.T) {
+	for _, tc := range []struct {
+		brand   CardBrand
+		version string
+		status  string
+		eci     string
+	}{
+		{CARD_BRAND_VISA, TDS_MSG_VER_1, TDS_TX_STATUS_Y, "05"},
+		{CARD_BRAND_VISA, TDS_MSG_VER_1, TDS_TX_STATUS_A, "06"},
+		{CARD_BRAND_VISA, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_N, "07"},
+		{CARD_BRAND_VISA, EMV3DS_VER_231, EMV3DS_TRANS_STATUS_I, "07"},
+		{CARD_BRAND_MASTERCARD, TDS_MSG_VER_1, TDS_TX_STATUS_Y, "02"},
+		{CARD_BRAND_MASTERCARD, EMV3DS_VER_210, EMV3DS_TRANS_STATUS_A, "01"},
+		{CARD_BRAND_MASTERCARD, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_R, "00"},
+		{CARD_BRAND_MASTERCARD, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_I, "06"},
+		{CARD_BRAND_AMEX, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_Y, "05"},
+		{CARD_BRAND_JCB, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_A, "06"},
+		{CARD_BRAND_MIR, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_U, "07"},
+	} {
+		eci, err := DeriveECI(tc.brand, tc.version, tc.status)
+		if err != nil || eci != tc.eci {
+			t.Fatalf("[ECI]: Invalid ECI for %s %s/%s: %s, expected: %s (%v)\n", tc.brand, tc.version, tc.status, eci, tc.eci, err)
+		}
+	}
+	for _, tc := range []struct {
+		brand   CardBrand
+		version string
+		status  string
+	}{
+		{CARD_BRAND_UNKNOWN, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_Y},
+		{CARD_BRAND_VISA, "2.0.0", EMV3DS_TRANS_STATUS_Y},
+		{CARD_BRAND_VISA, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_C},
+		{CARD_BRAND_VISA, EMV3DS_VER_210, EMV3DS_TRANS_STATUS_I},
+		{CARD_BRAND_MASTERCARD, TDS_MSG_VER_1, EMV3DS_TRANS_STATUS_R},
+	} {
+		if eci, err := DeriveECI(tc.brand, tc.version, tc.status); err == nil {
+			t.Fatalf("[ECI]: Derived ECI %s for %s %s/%s\n", eci, tc.brand, tc.version, tc.status)
+		}
+	}
+	if err := CheckECI(CARD_BRAND_MASTERCARD, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_Y, "05"); err == nil {
+		t.Fatalf("[ECI]: Accepted VISA ECI for MasterCard\n")
+	}
+}
+
+// =============================================================================
+// Test ECI of decoded CAVV & AAV
+// =============================================================================
+func TestECIAuthenticationValue(t *testing.T) {
+	cavv, _ := hex.DecodeString(TEST_V_RS_CAVV)
+	d, err := ParseVisaCavv(cavv)
+	if err != nil {
+		t.Fatalf("[ECI]: Failed to parse CAVV: %s\n", err)
+	}
+	status := map[uint8]string{VISA_ARC_AUTHENTICATED: "Y", VISA_ARC_ATTEMPTS: "A", VISA_ARC_FAILED: "N"}[d.ARC]
+	if err := checkECIValue(d, CARD_BRAND_VISA, status); err != nil {
+		t.Fatalf("[ECI]: %s\n", err)
+	}
+
+	aav := make([]byte, 20)
+	aav[0] = MC_AAV_CB_AUTHENTICATED
+	m, err := ParseMasterCardAAV(aav)
+	if err != nil {
+		t.Fatalf("[ECI]: Failed to parse AAV: %s\n", err)
+	}
+	if err := checkECIValue(m, CARD_BRAND_MASTERCARD, EMV3DS_TRANS_STATUS_Y); err != nil {
+		t.Fatalf("[ECI]: %s\n", err)
+	}
+	if _, err := MasterCardECIForControlByte(0x00); err == nil {
+		t.Fatalf("[ECI]: Derived ECI for invalid control byte\n")
+	}
+
+	// IAV has no status: ECI is derived from transaction status
+	secret, _ := hex.DecodeString("B039878C1F96D212F509B2DC4CC8CD1B")
+	iav, err := GenerateMasterCardIAV("2226400099919520", TEST_MC_MERCH_NAME_IAV, 123456, 840, 0x2C1C0497, secret)
+	if err != nil || iav[0] != MC_IAV_CB {
+		t.Fatalf("[ECI]: Failed to generate IAV: %X (%v)\n", iav, err)
+	}
+	if _, err := MasterCardECIForControlByte(iav[0]); err == nil || !strings.Contains(err.Error(), "DeriveECI") {
+		t.Fatalf("[ECI]: Invalid error for IAV control byte: %v\n", err)
+	}
+	if eci, err := DeriveECI(CARD_BRAND_MASTERCARD, EMV3DS_VER_220, EMV3DS_TRANS_STATUS_A); err != nil || eci != ECI_MC_ATTEMPTS {
+		t.Fatalf("[ECI]: Invalid IAV attempts ECI: %s (%v)\n", eci, err)
+	}
+}
+
+// =============================================================================
+//  Helper function to check ECI of authentication value matches status
+// =============================================================================
+func checkECIValue(v interface{ ECI() (string, error) }, brand CardBrand, status string) error {
+	eci, err := v.ECI()
+	if err != nil {
+		return err
+	}
+	return CheckECI(brand, EMV3DS_VER_220, status, eci)
+}
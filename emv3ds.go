@@ -0,0 +1,217 @@
+package gocavv
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+/*
+EMV 3-D Secure 2.x messages are JSON objects exchanged between 3DS Server, DS and ACS:
+---------------------------------------------------------------------------------------------------------------
+| Message | Direction             |                            Meaning                                         |
+---------------------------------------------------------------------------------------------------------------
+|  AReq   | 3DS Server -> ACS     | Authentication request (frictionless flow or challenge decision)           |
+|  ARes   | ACS -> 3DS Server     | Authentication response: transStatus, authenticationValue, ECI             |
+|  CReq   | SDK/Browser -> ACS    | Challenge request                                                          |
+|  CRes   | ACS -> SDK/Browser    | Challenge response with UI data or completion indicator                    |
+|  RReq   | ACS -> 3DS Server     | Results request with final challenge outcome                               |
+|  RRes   | 3DS Server -> ACS     | Results response acknowledging RReq                                        |
+|  Erro   | any                   | Error message                                                              |
+---------------------------------------------------------------------------------------------------------------
+
+Supported message versions: 2.1.0, 2.2.0, 2.3.1
+*/
+
+const (
+	EMV3DS_VER_210 string = "2.1.0"
+	EMV3DS_VER_220 string = "2.2.0"
+	EMV3DS_VER_231 string = "2.3.1"
+
+	// Device channel
+	EMV3DS_CHANNEL_APP string = "01"
+	EMV3DS_CHANNEL_BRW string = "02"
+	EMV3DS_CHANNEL_3RI string = "03"
+
+	// Message category
+	EMV3DS_CATEGORY_PA  string = "01"
+	EMV3DS_CATEGORY_NPA string = "02"
+
+	// Transaction status
+	EMV3DS_TRANS_STATUS_Y string = "Y" /* Authentication verification successful */
+	EMV3DS_TRANS_STATUS_N string = "N" /* Not authenticated, transaction denied   */
+	EMV3DS_TRANS_STATUS_U string = "U" /* Authentication could not be performed   */
+	EMV3DS_TRANS_STATUS_A string = "A" /* Attempts processing performed           */
+	EMV3DS_TRANS_STATUS_C string = "C" /* Challenge required                      */
+	EMV3DS_TRANS_STATUS_D string = "D" /* Decoupled authentication confirmed      */
+	EMV3DS_TRANS_STATUS_R string = "R" /* Authentication rejected                 */
+	EMV3DS_TRANS_STATUS_I string = "I" /* Informational only                      */
+
+	// Maximum size of EMV 3-D Secure JSON message
+	EMV3DS_MSG_MAX_SIZE int = 64 << 10
+)
+
+// =============================================================================
+//  EMV 3-D Secure message extension
+// =============================================================================
+type EMV3DSMessageExtension struct {
+	Name                 string          `json:"name"`
+	ID                   string          `json:"id"`
+	CriticalityIndicator bool            `json:"criticalityIndicator"`
+	Data                 json.RawMessage `json:"data"`
+}
+
+// =============================================================================
+//  Helper function to check supported message version
+// =============================================================================
+func isEMV3DSVersion(v string) bool {
+	return v == EMV3DS_VER_210 || v == EMV3DS_VER_220 || v == EMV3DS_VER_231
+}
+
+// =============================================================================
+//  Helper function to check message header: type & version
+// =============================================================================
+func checkEMV3DSHeader(msgType, expType, version string) error {
+	if msgType != expType {
+		return fmt.Errorf("Invalid EMV 3-D Secure message type: %q, expected: %s", msgType, expType)
+	}
+	if !isEMV3DSVersion(version) {
+		return fmt.Errorf("Unsupported EMV 3-D Secure message version: %q", version)
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to check required field length (characters)
+// =============================================================================
+func checkEMV3DSField(name, value string, min, max int) error {
+	n := utf8.RuneCountInString(value)
+	if n == 0 {
+		return fmt.Errorf("Required EMV 3-D Secure field %s is missing", name)
+	}
+	if n < min || n > max {
+		return fmt.Errorf("Invalid EMV 3-D Secure field %s length: %d", name, n)
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to check optional field length (characters)
+// =============================================================================
+func checkEMV3DSOptional(name, value string, min, max int) error {
+	if value == "" {
+		return nil
+	}
+	return checkEMV3DSField(name, value, min, max)
+}
+
+// =============================================================================
+//  Helper function to check required numeric field
+// =============================================================================
+func checkEMV3DSDigits(name, value string, min, max int) error {
+	if err := checkEMV3DSField(name, value, min, max); err != nil {
+		return err
+	}
+	if !isDigits(value) {
+		return fmt.Errorf("Invalid EMV 3-D Secure field %s: %q", name, value)
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to check required value from list
+// =============================================================================
+func checkEMV3DSEnum(name, value string, values ...string) error {
+	for _, v := range values {
+		if value == v {
+			return nil
+		}
+	}
+	if value == "" {
+		return fmt.Errorf("Required EMV 3-D Secure field %s is missing", name)
+	}
+	return fmt.Errorf("Invalid EMV 3-D Secure field %s: %q", name, value)
+}
+
+// =============================================================================
+//  Helper function to check transaction id (canonical UUID, 36 chars)
+// =============================================================================
+func checkEMV3DSTransID(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("Required EMV 3-D Secure field %s is missing", name)
+	}
+	if len(value) != 36 {
+		return fmt.Errorf("Invalid EMV 3-D Secure field %s: %q", name, value)
+	}
+	for i, r := range value {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return fmt.Errorf("Invalid EMV 3-D Secure field %s: %q", name, value)
+			}
+		default:
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+				return fmt.Errorf("Invalid EMV 3-D Secure field %s: %q", name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to decode authenticationValue (CAVV / AAV): 20 bytes, 21
+//  bytes for Master Card SPA2 AAV
+// =============================================================================
+func decodeEMV3DSAuthValue(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("Required EMV 3-D Secure field authenticationValue is missing")
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(s) != 28 || (len(b) != 20 && len(b) != 21) {
+		return nil, fmt.Errorf("Invalid EMV 3-D Secure field authenticationValue: %q", s)
+	}
+	return b, nil
+}
+
+// =============================================================================
+//  Helper function to encode authenticationValue
+// =============================================================================
+func encodeEMV3DSAuthValue(av []byte) (string, error) {
+	if len(av) != 20 && len(av) != 21 {
+		return "", fmt.Errorf("Invalid authentication value length: %d", len(av))
+	}
+	return base64.StdEncoding.EncodeToString(av), nil
+}
+
+// =============================================================================
+//  Helper function to validate message extensions
+// =============================================================================
+func validateEMV3DSExtensions(exts []EMV3DSMessageExtension) error {
+	if len(exts) > 10 {
+		return fmt.Errorf("Invalid number of EMV 3-D Secure message extensions: %d", len(exts))
+	}
+	for _, e := range exts {
+		if err := checkEMV3DSField("messageExtension.name", e.Name, 1, 64); err != nil {
+			return err
+		}
+		if err := checkEMV3DSField("messageExtension.id", e.ID, 1, 64); err != nil {
+			return err
+		}
+		if len(e.Data) > 8059 {
+			return fmt.Errorf("Invalid EMV 3-D Secure message extension %s data length: %d", e.ID, len(e.Data))
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to unmarshal JSON message (size is limited to
+//  EMV3DS_MSG_MAX_SIZE)
+// =============================================================================
+func unmarshalEMV3DSMessage(data []byte, v interface{}) error {
+	if len(data) > EMV3DS_MSG_MAX_SIZE {
+		return fmt.Errorf("EMV 3-D Secure message exceeds maximum size: %d", EMV3DS_MSG_MAX_SIZE)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,410 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+JSON Web Encryption (RFC 7516, compact serialization) used by EMV 3-D Secure 2.x:
+-----------------------------------------------------------------------------------------------------------------
+|  Message         |  alg            |  enc                    |  Key                                           |
+-----------------------------------------------------------------------------------------------------------------
+|  sdkEncData      |  RSA-OAEP-256   |  A128CBC-HS256          |  DS RSA public key, random CEK                 |
+|  sdkEncData      |  ECDH-ES        |  A128CBC-HS256          |  DS EC public key, ephemeral key in header     |
+|  CReq / CRes     |  dir            |  A128CBC-HS256, A128GCM |  Challenge key (ECDH-ES SDK & ACS ephemeral)   |
+-----------------------------------------------------------------------------------------------------------------
+
+JWE compact: BASE64URL(header) . BASE64URL(encrypted key) . BASE64URL(IV) . BASE64URL(ciphertext) . BASE64URL(tag)
+*/
+
+const (
+	// Key management algorithm
+	JWE_ALG_RSA_OAEP_256 string = "RSA-OAEP-256"
+	JWE_ALG_ECDH_ES      string = "ECDH-ES"
+	JWE_ALG_DIR          string = "dir"
+
+	// Content encryption algorithm
+	JWE_ENC_A128CBC_HS256 string = "A128CBC-HS256"
+	JWE_ENC_A128GCM       string = "A128GCM"
+)
+
+// =============================================================================
+//  JWE protected header
+// =============================================================================
+type JWEHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid,omitempty"`
+	EPK *JWK   `json:"epk,omitempty"`
+	APU string `json:"apu,omitempty"`
+	APV string `json:"apv,omitempty"`
+}
+
+// =============================================================================
+//  Helper function to get content encryption key length for enc
+// =============================================================================
+func jweKeyLen(enc string) (int, error) {
+	switch enc {
+	case JWE_ENC_A128CBC_HS256:
+		return 32, nil
+	case JWE_ENC_A128GCM:
+		return 16, nil
+	}
+	return 0, fmt.Errorf("Unsupported JWE content encryption algorithm: %q", enc)
+}
+
+// =============================================================================
+//  Encrypt plaintext to JWE with recipient public key: RSA-OAEP-256 for RSA,
+//  ECDH-ES (direct key agreement, P-256) for EC keys
+// =============================================================================
+func EncryptJWE(plaintext []byte, pub crypto.PublicKey, enc, kid string) (string, error) {
+	n, err := jweKeyLen(enc)
+	if err != nil {
+		return "", err
+	}
+	h := &JWEHeader{Enc: enc, Kid: kid}
+	var cek, ek []byte
+
+	if rk, ok := pub.(*rsa.PublicKey); ok {
+		h.Alg = JWE_ALG_RSA_OAEP_256
+		cek = make([]byte, n)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		if ek, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, rk, cek, nil); err != nil {
+			return "", err
+		}
+	} else {
+		pk, err := toECDHPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		epk, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		z, err := epk.ECDH(pk)
+		if err != nil {
+			return "", err
+		}
+		h.Alg = JWE_ALG_ECDH_ES
+		if h.EPK, err = NewJWK(epk.PublicKey()); err != nil {
+			return "", err
+		}
+		cek = concatKDF(z, n, []byte(enc), nil, nil)
+		zeroBytes(z)
+	}
+	defer zeroBytes(cek)
+	return encryptJWE(h, cek, ek, plaintext)
+}
+
+// =============================================================================
+//  Decrypt JWE with recipient private key (*rsa.PrivateKey for RSA-OAEP-256,
+//  *ecdsa.PrivateKey or *ecdh.PrivateKey for ECDH-ES)
+// =============================================================================
+func DecryptJWE(jwe string, priv crypto.PrivateKey) ([]byte, *JWEHeader, error) {
+	h, parts, err := parseJWE(jwe)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := jweKeyLen(h.Enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cek []byte
+
+	switch h.Alg {
+	case JWE_ALG_RSA_OAEP_256:
+		rk, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("RSA private key is required for JWE algorithm %s", h.Alg)
+		}
+		if cek, err = rsa.DecryptOAEP(sha256.New(), nil, rk, parts[1], nil); err != nil || len(cek) != n {
+			return nil, nil, fmt.Errorf("JWE decryption failed")
+		}
+	case JWE_ALG_ECDH_ES:
+		if len(parts[1]) != 0 {
+			return nil, nil, fmt.Errorf("JWE encrypted key must be empty for algorithm %s", h.Alg)
+		}
+		if h.EPK == nil {
+			return nil, nil, fmt.Errorf("JWE ephemeral public key (epk) is missing")
+		}
+		pk, err := h.EPK.ecdhPublicKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		k, err := toECDHPrivateKey(priv)
+		if err != nil {
+			return nil, nil, err
+		}
+		apu, err1 := base64.RawURLEncoding.DecodeString(h.APU)
+		apv, err2 := base64.RawURLEncoding.DecodeString(h.APV)
+		if err1 != nil || err2 != nil {
+			return nil, nil, fmt.Errorf("Invalid JWE apu / apv header")
+		}
+		z, err := k.ECDH(pk)
+		if err != nil {
+			return nil, nil, err
+		}
+		cek = concatKDF(z, n, []byte(h.Enc), apu, apv)
+		zeroBytes(z)
+	default:
+		return nil, nil, fmt.Errorf("Unsupported JWE key management algorithm: %q", h.Alg)
+	}
+	defer zeroBytes(cek)
+	pt, err := decryptJWE(h.Enc, cek, parts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pt, h, nil
+}
+
+// =============================================================================
+//  Encrypt plaintext to JWE with shared symmetric key (alg dir)
+// =============================================================================
+func EncryptJWEDirect(plaintext, key []byte, enc, kid string) (string, error) {
+	n, err := jweKeyLen(enc)
+	if err != nil {
+		return "", err
+	}
+	if len(key) != n {
+		return "", fmt.Errorf("Invalid JWE %s key length: %d", enc, len(key))
+	}
+	return encryptJWE(&JWEHeader{Alg: JWE_ALG_DIR, Enc: enc, Kid: kid}, key, nil, plaintext)
+}
+
+// =============================================================================
+//  Decrypt JWE with shared symmetric key (alg dir)
+// =============================================================================
+func DecryptJWEDirect(jwe string, key []byte) ([]byte, *JWEHeader, error) {
+	h, parts, err := parseJWE(jwe)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Alg != JWE_ALG_DIR {
+		return nil, nil, fmt.Errorf("Invalid JWE key management algorithm: %q, expected: %s", h.Alg, JWE_ALG_DIR)
+	}
+	if len(parts[1]) != 0 {
+		return nil, nil, fmt.Errorf("JWE encrypted key must be empty for algorithm %s", h.Alg)
+	}
+	n, err := jweKeyLen(h.Enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(key) != n {
+		return nil, nil, fmt.Errorf("Invalid JWE %s key length: %d", h.Enc, len(key))
+	}
+	pt, err := decryptJWE(h.Enc, key, parts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pt, h, nil
+}
+
+// =============================================================================
+//  Helper function to split & decode JWE compact serialization
+// =============================================================================
+func parseJWE(jwe string) (*JWEHeader, [][]byte, error) {
+	if len(jwe) > EMV3DS_MSG_MAX_SIZE {
+		return nil, nil, fmt.Errorf("JWE exceeds maximum size: %d", EMV3DS_MSG_MAX_SIZE)
+	}
+	s := strings.Split(jwe, ".")
+	if len(s) != 5 {
+		return nil, nil, fmt.Errorf("Invalid JWE compact serialization")
+	}
+	parts := make([][]byte, 5)
+	for i := range s {
+		b, err := base64.RawURLEncoding.DecodeString(s[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid JWE part %d encoding", i+1)
+		}
+		parts[i] = b
+	}
+	h := &JWEHeader{}
+	if err := json.Unmarshal(parts[0], h); err != nil {
+		return nil, nil, fmt.Errorf("Invalid JWE header: %s", err)
+	}
+	// AAD is ASCII of encoded protected header
+	parts[0] = []byte(s[0])
+	return h, parts, nil
+}
+
+// =============================================================================
+//  Helper function to encrypt content & serialize JWE
+// =============================================================================
+func encryptJWE(h *JWEHeader, cek, ek, plaintext []byte) (string, error) {
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	aad := base64.RawURLEncoding.EncodeToString(hb)
+	var iv, ct, tag []byte
+
+	switch h.Enc {
+	case JWE_ENC_A128CBC_HS256:
+		iv = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(iv); err != nil {
+			return "", err
+		}
+		block, err := aes.NewCipher(cek[16:])
+		if err != nil {
+			return "", err
+		}
+		ct = pkcs7Pad(plaintext, aes.BlockSize)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ct, ct)
+		tag = cbcHmacTag(cek[:16], []byte(aad), iv, ct)
+	case JWE_ENC_A128GCM:
+		gcm, err := newGCM(cek)
+		if err != nil {
+			return "", err
+		}
+		iv = make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(iv); err != nil {
+			return "", err
+		}
+		sealed := gcm.Seal(nil, iv, plaintext, []byte(aad))
+		ct, tag = sealed[:len(plaintext)], sealed[len(plaintext):]
+	default:
+		return "", fmt.Errorf("Unsupported JWE content encryption algorithm: %q", h.Enc)
+	}
+	enc := base64.RawURLEncoding
+	return strings.Join([]string{aad, enc.EncodeToString(ek), enc.EncodeToString(iv),
+		enc.EncodeToString(ct), enc.EncodeToString(tag)}, "."), nil
+}
+
+// =============================================================================
+//  Helper function to verify tag & decrypt JWE content
+// =============================================================================
+func decryptJWE(enc string, cek []byte, parts [][]byte) ([]byte, error) {
+	aad, iv, ct, tag := parts[0], parts[2], parts[3], parts[4]
+
+	switch enc {
+	case JWE_ENC_A128CBC_HS256:
+		if len(iv) != aes.BlockSize || len(ct) == 0 || len(ct)%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("Invalid JWE ciphertext length: %d", len(ct))
+		}
+		if subtle.ConstantTimeCompare(tag, cbcHmacTag(cek[:16], aad, iv, ct)) != 1 {
+			return nil, fmt.Errorf("Invalid JWE authentication tag")
+		}
+		block, err := aes.NewCipher(cek[16:])
+		if err != nil {
+			return nil, err
+		}
+		pt := make([]byte, len(ct))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(pt, ct)
+		return pkcs7Unpad(pt, aes.BlockSize)
+	case JWE_ENC_A128GCM:
+		gcm, err := newGCM(cek)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+			return nil, fmt.Errorf("Invalid JWE IV / tag length")
+		}
+		pt, err := gcm.Open(nil, iv, append(append([]byte{}, ct...), tag...), aad)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JWE authentication tag")
+		}
+		return pt, nil
+	}
+	return nil, fmt.Errorf("Unsupported JWE content encryption algorithm: %q", enc)
+}
+
+// =============================================================================
+//  Helper function to create AES GCM cipher
+// =============================================================================
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// =============================================================================
+//  Helper function to calculate A128CBC-HS256 tag (RFC 7518 5.2.2.1):
+//  HMAC-SHA256(AAD || IV || C || AL) truncated to 16 bytes
+// =============================================================================
+func cbcHmacTag(key, aad, iv, ct []byte) []byte {
+	al := make([]byte, 8)
+	binary.BigEndian.PutUint64(al, uint64(len(aad))*8)
+	m := hmac.New(sha256.New, key)
+	m.Write(aad)
+	m.Write(iv)
+	m.Write(ct)
+	m.Write(al)
+	return m.Sum(nil)[:16]
+}
+
+// =============================================================================
+//  Helper function to pad data (PKCS#7)
+// =============================================================================
+func pkcs7Pad(data []byte, size int) []byte {
+	n := size - len(data)%size
+	b := make([]byte, len(data)+n)
+	copy(b, data)
+	for i := len(data); i < len(b); i++ {
+		b[i] = byte(n)
+	}
+	return b
+}
+
+// =============================================================================
+//  Helper function to remove padding (PKCS#7)
+// =============================================================================
+func pkcs7Unpad(data []byte, size int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("Invalid padding")
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > size || n > len(data) {
+		return nil, fmt.Errorf("Invalid padding")
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, fmt.Errorf("Invalid padding")
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// =============================================================================
+//  Concat KDF (NIST SP 800-56A, RFC 7518 4.6.2) with SHA-256: keyLen bytes
+//  derived from shared secret Z, AlgorithmID, PartyUInfo & PartyVInfo
+// =============================================================================
+func concatKDF(z []byte, keyLen int, alg, apu, apv []byte) []byte {
+	lv := func(b []byte) []byte {
+		l := make([]byte, 4, 4+len(b))
+		binary.BigEndian.PutUint32(l, uint32(len(b)))
+		return append(l, b...)
+	}
+	var other []byte
+	other = append(other, lv(alg)...)
+	other = append(other, lv(apu)...)
+	other = append(other, lv(apv)...)
+	other = binary.BigEndian.AppendUint32(other, uint32(keyLen*8))
+
+	var out []byte
+	for c := uint32(1); len(out) < keyLen; c++ {
+		h := sha256.New()
+		binary.Write(h, binary.BigEndian, c)
+		h.Write(z)
+		h.Write(other)
+		out = h.Sum(out)
+	}
+	zeroBytes(out[keyLen:])
+	return out[:keyLen]
+}
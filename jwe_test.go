@@ -0,0 +1,146 @@
+package gocavv
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Test Concat KDF (RFC 7518 Appendix C)
+// =============================================================================
+func TestJWEConcatKDF(t *testing.T) {
+	z := []byte{158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132, 38, 156,
+		251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121, 140, 254, 144, 196}
+	k := concatKDF(z, 16, []byte(JWE_ENC_A128GCM), []byte("Alice"), []byte("Bob"))
+	if s := base64.RawURLEncoding.EncodeToString(k); s != "VqqN6vgjbSBcIijNcacQGg" {
+		t.Fatalf("[JWE]: Invalid Concat KDF key: %s\n", s)
+	}
+}
+
+// =============================================================================
+// Test sdkEncData JWE with DS RSA & EC keys
+// =============================================================================
+func TestJWEEncrypt(t *testing.T) {
+	plain := []byte(`{"DV":"1.0","DD":{"C001":"Android"},"DPNA":{},"SW":[],"SC":[]}`)
+
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("[JWE]: Failed to generate RSA key: %s\n", err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("[JWE]: Failed to generate EC key: %s\n", err)
+	}
+	for _, tc := range []struct {
+		alg  string
+		pub  interface{}
+		priv interface{}
+	}{
+		{JWE_ALG_RSA_OAEP_256, &rk.PublicKey, rk},
+		{JWE_ALG_ECDH_ES, &ek.PublicKey, ek},
+	} {
+		for _, enc := range []string{JWE_ENC_A128CBC_HS256, JWE_ENC_A128GCM} {
+			jwe, err := EncryptJWE(plain, tc.pub, enc, "ds-key-1")
+			if err != nil {
+				t.Fatalf("[JWE]: Failed to encrypt %s/%s: %s\n", tc.alg, enc, err)
+			}
+			data, h, err := DecryptJWE(jwe, tc.priv)
+			if err != nil || !bytes.Equal(data, plain) {
+				t.Fatalf("[JWE]: Failed to decrypt %s/%s: %v\n", tc.alg, enc, err)
+			}
+			if h.Alg != tc.alg || h.Enc != enc || h.Kid != "ds-key-1" {
+				t.Fatalf("[JWE]: Invalid JWE header: %+v\n", h)
+			}
+			// Modified ciphertext must be rejected
+			p := strings.Split(jwe, ".")
+			ct, _ := base64.RawURLEncoding.DecodeString(p[3])
+			ct[0] ^= 1
+			p[3] = base64.RawURLEncoding.EncodeToString(ct)
+			if _, _, err := DecryptJWE(strings.Join(p, "."), tc.priv); err == nil {
+				t.Fatalf("[JWE]: Decrypted modified JWE %s/%s\n", tc.alg, enc)
+			}
+		}
+	}
+	// Decryption with another key type
+	jwe, _ := EncryptJWE(plain, &ek.PublicKey, JWE_ENC_A128CBC_HS256, "")
+	if _, _, err := DecryptJWE(jwe, rk); err == nil {
+		t.Fatalf("[JWE]: Decrypted ECDH-ES JWE with RSA key\n")
+	}
+
+	areq := testAReqApp(EMV3DS_VER_220)
+	areq.SDKEncData, _ = EncryptJWE(plain, &rk.PublicKey, JWE_ENC_A128CBC_HS256, "")
+	if data, err := areq.DecryptSDKEncData(rk); err != nil || !bytes.Equal(data, plain) {
+		t.Fatalf("[JWE]: Failed to decrypt AReq sdkEncData: %v\n", err)
+	}
+}
+
+// =============================================================================
+// Test challenge channel key agreement & CReq/CRes protection
+// =============================================================================
+func TestJWEChallengeChannel(t *testing.T) {
+	sdkKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	acsKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+
+	areq := testAReqApp(EMV3DS_VER_220)
+	jwk, err := NewJWK(sdkKey.PublicKey())
+	if err != nil {
+		t.Fatalf("[JWE]: Failed to create JWK: %s\n", err)
+	}
+	if areq.SDKEphemPubKey, err = jwk.Marshal(); err != nil {
+		t.Fatalf("[JWE]: Failed to marshal JWK: %s\n", err)
+	}
+
+	for _, enc := range []string{JWE_ENC_A128CBC_HS256, JWE_ENC_A128GCM} {
+		acs, err := NewACSChallengeChannel(areq, TEST_3DS_ACS_TRANS_ID, acsKey, enc)
+		if err != nil {
+			t.Fatalf("[JWE]: Failed to create ACS challenge channel: %s\n", err)
+		}
+		key, err := DeriveChallengeKey(sdkKey, acsKey.PublicKey(), areq.SDKReferenceNumber)
+		if err != nil || !bytes.Equal(key, acs.Key) {
+			t.Fatalf("[JWE]: SDK & ACS challenge keys do not match: %v\n", err)
+		}
+		sdk := &ChallengeChannel{Key: key, Enc: enc, ACSTransID: TEST_3DS_ACS_TRANS_ID}
+
+		creq := testCReqApp()
+		creq.ChallengeDataEntry = "123456"
+		jwe, err := sdk.EncryptCReq(creq)
+		if err != nil {
+			t.Fatalf("[JWE]: Failed to encrypt CReq: %s\n", err)
+		}
+		r, err := acs.DecryptCReq(jwe)
+		if err != nil || r.ChallengeDataEntry != "123456" {
+			t.Fatalf("[JWE]: Failed to decrypt CReq: %v\n", err)
+		}
+		// CRes direction key differs for A128GCM
+		if _, err := sdk.DecryptCRes(jwe, creq); err == nil && enc == JWE_ENC_A128GCM {
+			t.Fatalf("[JWE]: Decrypted CReq as CRes with A128GCM\n")
+		}
+
+		jwe, err = acs.EncryptCRes(testCRes(r, "Y", EMV3DS_TRANS_STATUS_Y), r)
+		if err != nil {
+			t.Fatalf("[JWE]: Failed to encrypt CRes: %s\n", err)
+		}
+		res, err := sdk.DecryptCRes(jwe, creq)
+		if err != nil || !res.IsCompleted() {
+			t.Fatalf("[JWE]: Failed to decrypt CRes: %v\n", err)
+		}
+		other := &ChallengeChannel{Key: key, Enc: enc, ACSTransID: TEST_3DS_DS_TRANS_ID}
+		if _, err := other.DecryptCRes(jwe, creq); err == nil {
+			t.Fatalf("[JWE]: Decrypted CRes with another acsTransID\n")
+		}
+		acs.Zero()
+	}
+
+	// Invalid point in sdkEphemPubKey
+	areq.SDKEphemPubKey = []byte(`{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}`)
+	if _, err := NewACSChallengeChannel(areq, TEST_3DS_ACS_TRANS_ID, acsKey, JWE_ENC_A128GCM); err == nil {
+		t.Fatalf("[JWE]: Created challenge channel with invalid SDK ephemeral key\n")
+	}
+}
@@ -0,0 +1,200 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+/*
+JSON Web Key (RFC 7517) public keys used by EMV 3-D Secure 2.x:
+-----------------------------------------------------------------------------------------------
+|  Key                  |  kty  |  Fields         |  Usage                                    |
+-----------------------------------------------------------------------------------------------
+|  DS encryption key    |  RSA  |  n, e           |  sdkEncData JWE (RSA-OAEP-256)            |
+|  DS encryption key    |  EC   |  crv, x, y      |  sdkEncData JWE (ECDH-ES), P-256 only     |
+|  SDK ephemeral key    |  EC   |  crv, x, y      |  sdkEphemPubKey, challenge key agreement  |
+|  ACS ephemeral key    |  EC   |  crv, x, y      |  acsEphemPubKey, challenge key agreement  |
+-----------------------------------------------------------------------------------------------
+*/
+
+const (
+	JWK_KTY_RSA  string = "RSA"
+	JWK_KTY_EC   string = "EC"
+	JWK_CRV_P256 string = "P-256"
+)
+
+// =============================================================================
+//  JSON Web Key (public key only)
+// =============================================================================
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// =============================================================================
+//  Create JWK from RSA, ECDSA or ECDH (P-256) public key
+// =============================================================================
+func NewJWK(pub crypto.PublicKey) (*JWK, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: JWK_KTY_RSA,
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("Unsupported JWK elliptic curve: %s", k.Curve.Params().Name)
+		}
+		pk, err := k.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		return NewJWK(pk)
+	case *ecdh.PublicKey:
+		if k.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("Unsupported JWK elliptic curve")
+		}
+		b := k.Bytes() /* 0x04 || X || Y */
+		return &JWK{
+			Kty: JWK_KTY_EC,
+			Crv: JWK_CRV_P256,
+			X:   base64.RawURLEncoding.EncodeToString(b[1:33]),
+			Y:   base64.RawURLEncoding.EncodeToString(b[33:]),
+		}, nil
+	}
+	return nil, fmt.Errorf("Unsupported JWK public key type: %T", pub)
+}
+
+// =============================================================================
+//  Parse JWK JSON object
+// =============================================================================
+func ParseJWK(data []byte) (*JWK, error) {
+	k := &JWK{}
+	if err := json.Unmarshal(data, k); err != nil {
+		return nil, fmt.Errorf("Invalid JWK: %s", err)
+	}
+	if _, err := k.PublicKey(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// =============================================================================
+//  Marshal JWK to JSON object
+// =============================================================================
+func (k *JWK) Marshal() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+// =============================================================================
+//  Public key of JWK: *rsa.PublicKey or *ecdsa.PublicKey (P-256)
+// =============================================================================
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case JWK_KTY_RSA:
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) < 256 {
+			return nil, fmt.Errorf("Invalid JWK RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("Invalid JWK RSA exponent")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.E < 3 || pub.E&1 == 0 {
+			return nil, fmt.Errorf("Invalid JWK RSA exponent: %d", pub.E)
+		}
+		return pub, nil
+	case JWK_KTY_EC:
+		pk, err := k.ecdhPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		b := pk.Bytes()
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(b[1:33]),
+			Y:     new(big.Int).SetBytes(b[33:]),
+		}, nil
+	}
+	return nil, fmt.Errorf("Unsupported JWK key type: %q", k.Kty)
+}
+
+// =============================================================================
+//  Helper function to get ECDH public key of EC JWK (point is checked to be
+//  on curve)
+// =============================================================================
+func (k *JWK) ecdhPublicKey() (*ecdh.PublicKey, error) {
+	if k.Kty != JWK_KTY_EC {
+		return nil, fmt.Errorf("Invalid JWK key type for key agreement: %q", k.Kty)
+	}
+	if k.Crv != JWK_CRV_P256 {
+		return nil, fmt.Errorf("Unsupported JWK elliptic curve: %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != 32 {
+		return nil, fmt.Errorf("Invalid JWK EC x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil || len(y) != 32 {
+		return nil, fmt.Errorf("Invalid JWK EC y coordinate")
+	}
+	pk, err := ecdh.P256().NewPublicKey(append(append([]byte{0x04}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid JWK EC public key: %s", err)
+	}
+	return pk, nil
+}
+
+// =============================================================================
+//  Helper function to convert public key to P-256 ECDH public key
+// =============================================================================
+func toECDHPublicKey(pub crypto.PublicKey) (*ecdh.PublicKey, error) {
+	switch k := pub.(type) {
+	case *ecdh.PublicKey:
+		if k.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("Unsupported elliptic curve for key agreement")
+		}
+		return k, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("Unsupported elliptic curve for key agreement: %s", k.Curve.Params().Name)
+		}
+		return k.ECDH()
+	case *JWK:
+		return k.ecdhPublicKey()
+	}
+	return nil, fmt.Errorf("Unsupported public key type for key agreement: %T", pub)
+}
+
+// =============================================================================
+//  Helper function to convert private key to P-256 ECDH private key
+// =============================================================================
+func toECDHPrivateKey(priv crypto.PrivateKey) (*ecdh.PrivateKey, error) {
+	switch k := priv.(type) {
+	case *ecdh.PrivateKey:
+		if k.Curve() != ecdh.P256() {
+			return nil, fmt.Errorf("Unsupported elliptic curve for key agreement")
+		}
+		return k, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("Unsupported elliptic curve for key agreement: %s", k.Curve.Params().Name)
+		}
+		return k.ECDH()
+	}
+	return nil, fmt.Errorf("Unsupported private key type for key agreement: %T", priv)
+}
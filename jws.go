@@ -0,0 +1,290 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+/*
+acsSignedContent of app ARes EMV 3-D Secure 2.x is JWS (RFC 7515, compact serialization):
+------------------------------------------------------------------------------------------------------------------------
+|  Part                               |  Content                                                                       |
+------------------------------------------------------------------------------------------------------------------------
+|  header                             |  alg (PS256 for RSA, ES256 for P-256 key), x5c (ACS certificate chain)         |
+|  payload                            |  acsURL, acsEphemPubKey (JWK), sdkEphemPubKey (JWK from AReq)                  |
+|  signature                          |  RSASSA-PSS SHA-256 / ECDSA P-256 SHA-256 (R || S, 64 bytes)                   |
+------------------------------------------------------------------------------------------------------------------------
+The x5c chain (ACS certificate first) is verified against the DS root certificates of trust store
+*/
+
+const (
+	JWS_ALG_PS256 string = "PS256"
+	JWS_ALG_ES256 string = "ES256"
+)
+
+// =============================================================================
+//  JWS protected header
+// =============================================================================
+type JWSHeader struct {
+	Alg string   `json:"alg"`
+	Kid string   `json:"kid,omitempty"`
+	X5C []string `json:"x5c,omitempty"`
+}
+
+// =============================================================================
+//  acsSignedContent payload
+// =============================================================================
+type ACSSignedContent struct {
+	ACSURL         string `json:"acsURL"`
+	ACSEphemPubKey *JWK   `json:"acsEphemPubKey"`
+	SDKEphemPubKey *JWK   `json:"sdkEphemPubKey"`
+}
+
+// =============================================================================
+//  Sign payload to JWS with key (RSA: PS256, ECDSA P-256: ES256) and x5c
+//  certificate chain starting with signer certificate
+// =============================================================================
+func SignJWS(payload []byte, key crypto.Signer, chain []*x509.Certificate) (string, error) {
+	if key == nil {
+		return "", fmt.Errorf("JWS signing key is missing")
+	}
+	h := &JWSHeader{}
+	switch k := key.Public().(type) {
+	case *rsa.PublicKey:
+		h.Alg = JWS_ALG_PS256
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("Unsupported JWS elliptic curve: %s", k.Curve.Params().Name)
+		}
+		h.Alg = JWS_ALG_ES256
+	default:
+		return "", fmt.Errorf("Unsupported JWS signing key type: %T", k)
+	}
+	for _, c := range chain {
+		h.X5C = append(h.X5C, base64.StdEncoding.EncodeToString(c.Raw))
+	}
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	if h.Alg == JWS_ALG_PS256 {
+		sig, err = key.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+		if err != nil {
+			return "", err
+		}
+	} else {
+		der, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return "", err
+		}
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &rs); err != nil {
+			return "", fmt.Errorf("Invalid ECDSA signature: %s", err)
+		}
+		sig = make([]byte, 64)
+		rs.R.FillBytes(sig[:32])
+		rs.S.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// =============================================================================
+//  Verify JWS signature by x5c signer certificate & certificate chain against
+//  trust store, returns payload & signer certificate
+// =============================================================================
+func VerifyJWS(jws string, ts *TrustStore) ([]byte, *x509.Certificate, error) {
+	if len(jws) > 512000 {
+		return nil, nil, fmt.Errorf("JWS exceeds maximum size: %d", 512000)
+	}
+	s := strings.Split(jws, ".")
+	if len(s) != 3 {
+		return nil, nil, fmt.Errorf("Invalid JWS compact serialization")
+	}
+	hb, err := base64.RawURLEncoding.DecodeString(s[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid JWS header encoding")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(s[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid JWS payload encoding")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid JWS signature encoding")
+	}
+	h := &JWSHeader{}
+	if err := json.Unmarshal(hb, h); err != nil {
+		return nil, nil, fmt.Errorf("Invalid JWS header: %s", err)
+	}
+	if len(h.X5C) == 0 {
+		return nil, nil, fmt.Errorf("JWS x5c certificate chain is missing")
+	}
+	var chain []*x509.Certificate
+	for _, c := range h.X5C {
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid JWS x5c certificate encoding")
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid JWS x5c certificate: %s", err)
+		}
+		chain = append(chain, cert)
+	}
+	if err := ts.verify(chain[0], chain[1:]); err != nil {
+		return nil, nil, fmt.Errorf("JWS signer certificate verification failed: %s", err)
+	}
+
+	digest := sha256.Sum256([]byte(s[0] + "." + s[1]))
+	switch h.Alg {
+	case JWS_ALG_PS256:
+		pub, ok := chain[0].PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("JWS signer certificate key does not match algorithm %s", h.Alg)
+		}
+		if err := rsa.VerifyPSS(pub, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return nil, nil, fmt.Errorf("Invalid JWS signature")
+		}
+	case JWS_ALG_ES256:
+		pub, ok := chain[0].PublicKey.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != 256 {
+			return nil, nil, fmt.Errorf("JWS signer certificate key does not match algorithm %s", h.Alg)
+		}
+		if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, nil, fmt.Errorf("Invalid JWS signature")
+		}
+	default:
+		return nil, nil, fmt.Errorf("Unsupported JWS algorithm: %q", h.Alg)
+	}
+	return payload, chain[0], nil
+}
+
+// =============================================================================
+//  Create acsSignedContent signed by ACS key & certificate chain
+// =============================================================================
+func SignACSContent(c *ACSSignedContent, key crypto.Signer, chain []*x509.Certificate) (string, error) {
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return SignJWS(payload, key, chain)
+}
+
+// =============================================================================
+//  Verify acsSignedContent against DS roots of trust store, returns content
+//  & ACS signer certificate
+// =============================================================================
+func VerifyACSSignedContent(jws string, ts *TrustStore) (*ACSSignedContent, *x509.Certificate, error) {
+	payload, cert, err := VerifyJWS(jws, ts)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &ACSSignedContent{}
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, nil, fmt.Errorf("Invalid acsSignedContent payload: %s", err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, cert, nil
+}
+
+// =============================================================================
+//  Helper function to validate acsSignedContent payload
+// =============================================================================
+func (c *ACSSignedContent) validate() error {
+	if err := checkEMV3DSField("acsURL", c.ACSURL, 1, 2048); err != nil {
+		return err
+	}
+	if c.ACSEphemPubKey == nil {
+		return fmt.Errorf("Required acsSignedContent field acsEphemPubKey is missing")
+	}
+	if _, err := c.ACSEphemPubKey.ecdhPublicKey(); err != nil {
+		return err
+	}
+	if c.SDKEphemPubKey == nil {
+		return fmt.Errorf("Required acsSignedContent field sdkEphemPubKey is missing")
+	}
+	_, err := c.SDKEphemPubKey.ecdhPublicKey()
+	return err
+}
+
+// =============================================================================
+//  Set acsSignedContent of app ARes: ACS URL, ACS ephemeral key & AReq SDK
+//  ephemeral key signed by ACS
+// =============================================================================
+func (r *ARes) SignACSContent(req *AReq, acsURL string, acsEphemPubKey crypto.PublicKey, key crypto.Signer, chain []*x509.Certificate) error {
+	sdk := &JWK{}
+	if err := json.Unmarshal(req.SDKEphemPubKey, sdk); err != nil {
+		return fmt.Errorf("Invalid EMV 3-D Secure field sdkEphemPubKey: %s", err)
+	}
+	acs, err := NewJWK(acsEphemPubKey)
+	if err != nil {
+		return err
+	}
+	s, err := SignACSContent(&ACSSignedContent{ACSURL: acsURL, ACSEphemPubKey: acs, SDKEphemPubKey: sdk}, key, chain)
+	if err != nil {
+		return err
+	}
+	r.ACSSignedContent = s
+	return nil
+}
+
+// =============================================================================
+//  Verify acsSignedContent of app ARes (SDK side): signature is checked
+//  against trust store and SDK ephemeral key must be the one of AReq
+// =============================================================================
+func (r *ARes) VerifyACSSignedContent(req *AReq, ts *TrustStore) (*ACSSignedContent, error) {
+	if r.ACSSignedContent == "" {
+		return nil, fmt.Errorf("Required EMV 3-D Secure field acsSignedContent is missing")
+	}
+	c, _, err := VerifyACSSignedContent(r.ACSSignedContent, ts)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := req.SDKEphemeralKey()
+	if err != nil {
+		return nil, err
+	}
+	sdk, _ := c.SDKEphemPubKey.ecdhPublicKey()
+	if !sdk.Equal(pk) {
+		return nil, fmt.Errorf("acsSignedContent sdkEphemPubKey does not match AReq")
+	}
+	return c, nil
+}
+
+// =============================================================================
+//  Create challenge channel for SDK side from verified acsSignedContent and
+//  SDK ephemeral private key
+// =============================================================================
+func NewSDKChallengeChannel(req *AReq, res *ARes, sdkEphemKey crypto.PrivateKey, enc string, ts *TrustStore) (*ChallengeChannel, error) {
+	if _, err := jweKeyLen(enc); err != nil {
+		return nil, err
+	}
+	c, err := res.VerifyACSSignedContent(req, ts)
+	if err != nil {
+		return nil, err
+	}
+	key, err := DeriveChallengeKey(sdkEphemKey, c.ACSEphemPubKey, req.SDKReferenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	return &ChallengeChannel{Key: key, Enc: enc, ACSTransID: res.ACSTransID}, nil
+}
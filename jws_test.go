@@ -0,0 +1,109 @@
+package gocavv
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Test acsSignedContent JWS (PS256) & SDK challenge channel
+// =============================================================================
+func TestJWSACSSignedContent(t *testing.T) {
+	root, rootKey := testCertificate(t, "Test DS Root", true, nil, nil)
+	acsCert, acsKey := testCertificate(t, "Test ACS", false, root, rootKey)
+	ts := NewTrustStore(root)
+
+	sdkKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	acsEphem, _ := ecdh.P256().GenerateKey(rand.Reader)
+
+	areq := testAReqApp(EMV3DS_VER_220)
+	jwk, _ := NewJWK(sdkKey.PublicKey())
+	areq.SDKEphemPubKey, _ = jwk.Marshal()
+
+	ares := testARes(areq, EMV3DS_TRANS_STATUS_C)
+	if err := ares.SignACSContent(areq, "https://acs.example.com/challenge", acsEphem.PublicKey(), acsKey, []*x509.Certificate{acsCert}); err != nil {
+		t.Fatalf("[JWS]: Failed to sign acsSignedContent: %s\n", err)
+	}
+	c, cert, err := VerifyACSSignedContent(ares.ACSSignedContent, ts)
+	if err != nil || cert.Subject.CommonName != "Test ACS" || c.ACSURL != "https://acs.example.com/challenge" {
+		t.Fatalf("[JWS]: Failed to verify acsSignedContent: %v\n", err)
+	}
+
+	// SDK & ACS derive the same challenge key
+	sdk, err := NewSDKChallengeChannel(areq, ares, sdkKey, JWE_ENC_A128CBC_HS256, ts)
+	if err != nil {
+		t.Fatalf("[JWS]: Failed to create SDK challenge channel: %s\n", err)
+	}
+	acs, err := NewACSChallengeChannel(areq, ares.ACSTransID, acsEphem, JWE_ENC_A128CBC_HS256)
+	if err != nil || !bytes.Equal(sdk.Key, acs.Key) {
+		t.Fatalf("[JWS]: SDK & ACS challenge keys do not match: %v\n", err)
+	}
+
+	// Not trusted root
+	other, _ := testCertificate(t, "Other DS Root", true, nil, nil)
+	if _, err := ares.VerifyACSSignedContent(areq, NewTrustStore(other)); err == nil {
+		t.Fatalf("[JWS]: Verified acsSignedContent against another root\n")
+	}
+	if _, _, err := VerifyACSSignedContent(ares.ACSSignedContent, nil); err == nil {
+		t.Fatalf("[JWS]: Verified acsSignedContent without trust store\n")
+	}
+	// Modified payload
+	p := strings.Split(ares.ACSSignedContent, ".")
+	payload, _ := SignJWS([]byte(`{"acsURL":"https://evil.example.com"}`), acsKey, []*x509.Certificate{acsCert})
+	p[1] = strings.Split(payload, ".")[1]
+	if _, _, err := VerifyJWS(strings.Join(p, "."), ts); err == nil {
+		t.Fatalf("[JWS]: Verified JWS with modified payload\n")
+	}
+	// SDK ephemeral key of another AReq
+	areq2 := testAReqApp(EMV3DS_VER_220)
+	k2, _ := ecdh.P256().GenerateKey(rand.Reader)
+	jwk, _ = NewJWK(k2.PublicKey())
+	areq2.SDKEphemPubKey, _ = jwk.Marshal()
+	if _, err := ares.VerifyACSSignedContent(areq2, ts); err == nil {
+		t.Fatalf("[JWS]: Verified acsSignedContent for another SDK ephemeral key\n")
+	}
+}
+
+// =============================================================================
+// Test ES256 JWS with EC ACS certificate
+// =============================================================================
+func TestJWSES256(t *testing.T) {
+	root, rootKey := testCertificate(t, "Test DS Root", true, nil, nil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("[JWS]: Failed to generate EC key: %s\n", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Test ACS EC"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, root, &key.PublicKey, rootKey)
+	if err != nil {
+		t.Fatalf("[JWS]: Failed to create certificate: %s\n", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	jws, err := SignJWS([]byte(`{"test":1}`), key, []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatalf("[JWS]: Failed to sign ES256 JWS: %s\n", err)
+	}
+	if !strings.HasPrefix(jws, "eyJhbGciOiJFUzI1NiIs") {
+		t.Fatalf("[JWS]: Invalid ES256 JWS header: %s\n", strings.Split(jws, ".")[0])
+	}
+	payload, _, err := VerifyJWS(jws, NewTrustStore(root))
+	if err != nil || string(payload) != `{"test":1}` {
+		t.Fatalf("[JWS]: Failed to verify ES256 JWS: %v\n", err)
+	}
+}
@@ -0,0 +1,151 @@
+package gocavv
+
+import (
+	"fmt"
+)
+
+const (
+	KEY_REDACTED string = "[REDACTED]"
+)
+
+// =============================================================================
+//  KeyBytes is clear key material, it is never printed by fmt (%v, %x, %s)
+// =============================================================================
+type KeyBytes []byte
+
+func (k KeyBytes) String() string {
+	return KEY_REDACTED
+}
+
+func (k KeyBytes) GoString() string {
+	return KEY_REDACTED
+}
+
+func (k KeyBytes) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, KEY_REDACTED)
+}
+
+// =============================================================================
+//  Wipe key material
+// =============================================================================
+func (k KeyBytes) Zeroize() {
+	zeroBytes(k)
+}
+
+// =============================================================================
+//  SecretKey owns clear key material assembled in process memory (key
+//  ceremony, TR-31 import) together with its Key Check Value. Key must be
+//  destroyed when it is not needed any more
+// =============================================================================
+type SecretKey struct {
+	key KeyBytes
+	kcv string
+}
+
+// =============================================================================
+//  Create secret key taking ownership of key bytes (no copy is made, caller
+//  must not use key slice after this call)
+// =============================================================================
+func NewSecretKey(key []byte) (*SecretKey, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("Invalid key length: 0")
+	}
+	k := &SecretKey{key: key}
+	// Key Check Value is defined for DES keys only
+	if len(key) == 8 || len(key) == 16 || len(key) == 24 {
+		kcv, err := KeyCheckValue(key)
+		if err != nil {
+			return nil, err
+		}
+		k.kcv = kcv
+	}
+	return k, nil
+}
+
+// =============================================================================
+//  Wipe key material, key can not be used after destroy
+// =============================================================================
+func (k *SecretKey) Destroy() {
+	k.key.Zeroize()
+	k.key = nil
+}
+
+// =============================================================================
+//  Check if key is destroyed
+// =============================================================================
+func (k *SecretKey) Destroyed() bool {
+	return k.key == nil
+}
+
+// =============================================================================
+//  Key Check Value (6 hex digits)
+// =============================================================================
+func (k *SecretKey) KCV() string {
+	return k.kcv
+}
+
+// =============================================================================
+//  Key length in bytes
+// =============================================================================
+func (k *SecretKey) Len() int {
+	return len(k.key)
+}
+
+// =============================================================================
+//  Get CVK A & CVK B halves of double length key for GenerateVisaCavv,
+//  GenerateMasterCardAAV (CVC2). Halves share key memory, they are wiped
+//  by Destroy
+// =============================================================================
+func (k *SecretKey) CVK() ([]byte, []byte, error) {
+	if k.Destroyed() {
+		return nil, nil, fmt.Errorf("Key is destroyed")
+	}
+	if len(k.key) != 16 {
+		return nil, nil, fmt.Errorf("Invalid CVK length: %d, expected: 16", len(k.key))
+	}
+	return k.key[:8], k.key[8:], nil
+}
+
+// =============================================================================
+//  Create crypto provider for CAVV / CVC2 from double length CVK. Provider
+//  uses key memory, it fails once key is destroyed
+// =============================================================================
+func (k *SecretKey) CryptoProvider() (*SoftwareCryptoProvider, error) {
+	keyA, keyB, err := k.CVK()
+	if err != nil {
+		return nil, err
+	}
+	return &SoftwareCryptoProvider{keyA: keyA, keyB: keyB, owner: k}, nil
+}
+
+// =============================================================================
+//  Create MAC provider for Master Card AAV (HMAC-SHA1) & IAV (HMAC-SHA256).
+//  Provider uses key memory, it fails once key is destroyed
+// =============================================================================
+func (k *SecretKey) MacProvider() (*SoftwareMacProvider, error) {
+	if k.Destroyed() {
+		return nil, fmt.Errorf("Key is destroyed")
+	}
+	return &SoftwareMacProvider{key: k.key, owner: k}, nil
+}
+
+func (k SecretKey) String() string {
+	return fmt.Sprintf("SecretKey{len: %d, kcv: %s}", len(k.key), k.kcv)
+}
+
+func (k SecretKey) GoString() string {
+	return k.String()
+}
+
+func (k SecretKey) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, k.String())
+}
+
+// =============================================================================
+//  Helper function to wipe sensitive buffer
+// =============================================================================
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
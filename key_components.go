@@ -0,0 +1,149 @@
+package gocavv
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// =============================================================================
+//  Clear key component entered (or generated) under split knowledge with
+//  its Key Check Value
+// =============================================================================
+type KeyComponent struct {
+	Value KeyBytes
+	KCV   string
+}
+
+// =============================================================================
+//  Calculate TDES Key Check Value: encrypt block of binary zeros and take
+//  left most 3 bytes (6 hex digits)
+// =============================================================================
+func KeyCheckValue(key []byte) (string, error) {
+	c, err := createKeyCipher(key)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 8)
+	c.Encrypt(b, b)
+	return strings.ToUpper(hex.EncodeToString(b[:3])), nil
+}
+
+// =============================================================================
+//  Create key component from clear value (8, 16 or 24 bytes) and check it
+//  against the KCV recorded by the custodian. Component takes ownership of
+//  value, which is zeroized on error
+// =============================================================================
+func NewKeyComponent(value []byte, kcv string) (*KeyComponent, error) {
+	c := &KeyComponent{Value: value}
+	if err := c.Check(kcv); err != nil {
+		c.Zeroize()
+		return nil, err
+	}
+	return c, nil
+}
+
+// =============================================================================
+//  Generate random key component (8, 16 or 24 bytes) with odd parity
+// =============================================================================
+func GenerateKeyComponent(size int) (*KeyComponent, error) {
+	if size != 8 && size != 16 && size != 24 {
+		return nil, fmt.Errorf("Invalid key component length: %d", size)
+	}
+	c := &KeyComponent{Value: make(KeyBytes, size)}
+	if _, err := rand.Read(c.Value); err != nil {
+		return nil, err
+	}
+	setDESOddParity(c.Value)
+
+	kcv, err := KeyCheckValue(c.Value)
+	if err != nil {
+		c.Zeroize()
+		return nil, err
+	}
+	c.KCV = kcv
+	return c, nil
+}
+
+// =============================================================================
+//  Check component against Key Check Value
+// =============================================================================
+func (c *KeyComponent) Check(kcv string) error {
+	v, err := KeyCheckValue(c.Value)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(v), []byte(strings.ToUpper(kcv))) != 1 {
+		return fmt.Errorf("Key component check value mismatch: %s, expected: %s", v, strings.ToUpper(kcv))
+	}
+	c.KCV = v
+	return nil
+}
+
+// =============================================================================
+//  Wipe component value
+// =============================================================================
+func (c *KeyComponent) Zeroize() {
+	c.Value.Zeroize()
+	c.Value = nil
+}
+
+// =============================================================================
+//  Combine two or three key components with XOR into final key. Every
+//  component is checked against its KCV, the final key against kcv (if not
+//  empty). Components are zeroized in any case
+// =============================================================================
+func CombineKeyComponents(kcv string, components ...*KeyComponent) (*SecretKey, error) {
+	defer func() {
+		for _, c := range components {
+			c.Zeroize()
+		}
+	}()
+
+	if len(components) < 2 || len(components) > 3 {
+		return nil, fmt.Errorf("Invalid number of key components: %d, expected: 2 or 3", len(components))
+	}
+	size := len(components[0].Value)
+	for i, c := range components {
+		if len(c.Value) != size {
+			return nil, fmt.Errorf("Invalid key component %d length: %d, expected: %d", i+1, len(c.Value), size)
+		}
+		if err := c.Check(c.KCV); err != nil {
+			return nil, fmt.Errorf("Key component %d: %s", i+1, err)
+		}
+	}
+	// XOR components
+	key := make([]byte, size)
+	for _, c := range components {
+		for i := range key {
+			key[i] ^= c.Value[i]
+		}
+	}
+	setDESOddParity(key)
+
+	v, err := KeyCheckValue(key)
+	if err != nil {
+		zeroBytes(key)
+		return nil, err
+	}
+	if kcv != "" && subtle.ConstantTimeCompare([]byte(v), []byte(strings.ToUpper(kcv))) != 1 {
+		zeroBytes(key)
+		return nil, fmt.Errorf("Key check value mismatch: %s, expected: %s", v, strings.ToUpper(kcv))
+	}
+	return &SecretKey{key: key, kcv: v}, nil
+}
+
+// =============================================================================
+//  Helper function to adjust DES key to odd parity
+// =============================================================================
+func setDESOddParity(key []byte) {
+	for i, b := range key {
+		n := 0
+		for x := b >> 1; x > 0; x >>= 1 {
+			n += int(x & 1)
+		}
+		key[i] = b&0xFE | byte((n+1)%2)
+	}
+}
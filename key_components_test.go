@@ -0,0 +1,96 @@
+package gocavv
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+const (
+	TEST_KEY_CVK     string = "0123456789ABCDEFFEDCBA9876543210"
+	TEST_KEY_CVK_KCV string = "08D7B4"
+)
+
+// =============================================================================
+//  Test Key Check Value
+// =============================================================================
+func TestKey_CheckValue(t *testing.T) {
+	key, _ := hex.DecodeString(TEST_KEY_CVK)
+	kcv, err := KeyCheckValue(key)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to calculate KCV: %s\n", err)
+	}
+	if kcv != TEST_KEY_CVK_KCV {
+		t.Fatalf("[KEY]: Invalid KCV: %s, expected: %s\n", kcv, TEST_KEY_CVK_KCV)
+	}
+}
+
+// =============================================================================
+//  Test combination of generated components into CVK
+// =============================================================================
+func TestKey_CombineComponents(t *testing.T) {
+	key, _ := hex.DecodeString(TEST_KEY_CVK)
+
+	// Two random components & third one completing known CVK
+	c1, err := GenerateKeyComponent(16)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to generate key component: %s\n", err)
+	}
+	c2, err := GenerateKeyComponent(16)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to generate key component: %s\n", err)
+	}
+	v3 := make([]byte, 16)
+	for i := range v3 {
+		v3[i] = key[i] ^ c1.Value[i] ^ c2.Value[i]
+	}
+	kcv3, _ := KeyCheckValue(v3)
+	c3, err := NewKeyComponent(v3, kcv3)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to create key component: %s\n", err)
+	}
+
+	k, err := CombineKeyComponents(TEST_KEY_CVK_KCV, c1, c2, c3)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to combine key components: %s\n", err)
+	}
+	if k.KCV() != TEST_KEY_CVK_KCV {
+		t.Fatalf("[KEY]: Invalid combined key KCV: %s\n", k.KCV())
+	}
+	if c1.Value != nil || c2.Value != nil || c3.Value != nil || v3[0] != 0 {
+		t.Fatalf("[KEY]: Key components are not zeroized\n")
+	}
+
+	// Use combined key for CAVV
+	keyA, keyB, err := k.CVK()
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to get CVK A/B: %s\n", err)
+	}
+	cavv, err := GenerateVisaCavv(TEST_V_PAN_16, TEST_V_I_ATN, TEST_V_I_AUTH_RC, TEST_V_I_SECOND_ACODE, TEST_V_I_CAVV_KEY_ID, keyA, keyB)
+	if err != nil || hex.EncodeToString(cavv) != TEST_V_RS_CAVV {
+		t.Fatalf("[KEY]: Invalid CAVV with combined key: %X, %v\n", cavv, err)
+	}
+}
+
+// =============================================================================
+//  Test component & final KCV mismatch
+// =============================================================================
+func TestKey_CombineComponentsInvalidKCV(t *testing.T) {
+	v, _ := hex.DecodeString(TEST_KEY_CVK)
+	if _, err := NewKeyComponent(v, "000000"); err == nil {
+		t.Fatalf("[KEY]: Created key component with invalid KCV\n")
+	}
+	if v[0] != 0 {
+		t.Fatalf("[KEY]: Rejected key component is not zeroized\n")
+	}
+
+	c1, _ := GenerateKeyComponent(16)
+	c2, _ := GenerateKeyComponent(16)
+	if _, err := CombineKeyComponents("000000", c1, c2); err == nil {
+		t.Fatalf("[KEY]: Combined key with invalid KCV\n")
+	}
+
+	c1, _ = GenerateKeyComponent(16)
+	if _, err := CombineKeyComponents("", c1); err == nil {
+		t.Fatalf("[KEY]: Combined single key component\n")
+	}
+}
@@ -0,0 +1,163 @@
+package gocavv
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sync"
+)
+
+// =============================================================================
+//  KeyRegistry holds issuer key providers by card brand and key indicator
+//  carried in the authentication value (VISA CAVV Key Indicator, Master Card
+//  BIN Key Identifier). Safe for concurrent use
+// =============================================================================
+type KeyRegistry struct {
+	mu     sync.RWMutex
+	crypto map[keyRef]CryptoProvider
+	mac    map[keyRef]MacProvider
+}
+
+type keyRef struct {
+	brand CardBrand
+	id    uint8
+}
+
+// =============================================================================
+//  Result of authentication value verification (authorization side)
+// =============================================================================
+type AuthenticationResult struct {
+	Brand  CardBrand
+	Valid  bool   /* Cryptographic check passed                 */
+	Status string /* Transaction status Y or A, empty otherwise  */
+	ECI    string /* ECI expected in authorization for status   */
+	KeyID  uint8  /* Key indicator used to verify               */
+}
+
+// =============================================================================
+//  Create empty key registry
+// =============================================================================
+func NewKeyRegistry() *KeyRegistry {
+	return &KeyRegistry{
+		crypto: make(map[keyRef]CryptoProvider),
+		mac:    make(map[keyRef]MacProvider),
+	}
+}
+
+// =============================================================================
+//  Register CVV2 style crypto provider (CAVV key pair) for brand & key id
+// =============================================================================
+func (r *KeyRegistry) AddCryptoProvider(brand CardBrand, keyID uint8, p CryptoProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.crypto[keyRef{brand, keyID}] = p
+}
+
+// =============================================================================
+//  Register MAC provider (HMAC secret key) for brand & key id
+// =============================================================================
+func (r *KeyRegistry) AddMacProvider(brand CardBrand, keyID uint8, p MacProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mac[keyRef{brand, keyID}] = p
+}
+
+// =============================================================================
+//  Get crypto provider for brand & key id
+// =============================================================================
+func (r *KeyRegistry) CryptoProvider(brand CardBrand, keyID uint8) (CryptoProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.crypto[keyRef{brand, keyID}]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("No %s crypto provider for key indicator %d", brand, keyID)
+}
+
+// =============================================================================
+//  Get MAC provider for brand & key id
+// =============================================================================
+func (r *KeyRegistry) MacProvider(brand CardBrand, keyID uint8) (MacProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.mac[keyRef{brand, keyID}]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("No %s MAC provider for key indicator %d", brand, keyID)
+}
+
+// =============================================================================
+//  Helper function to create result for ECI of authentication value
+// =============================================================================
+func newAuthenticationResult(brand CardBrand, keyID uint8, eci string, valid bool) *AuthenticationResult {
+	res := &AuthenticationResult{Brand: brand, Valid: valid, ECI: eci, KeyID: keyID}
+	for _, s := range []string{EMV3DS_TRANS_STATUS_Y, EMV3DS_TRANS_STATUS_A} {
+		if e, _ := DeriveECI(brand, EMV3DS_VER_220, s); e == eci {
+			res.Status = s
+		}
+	}
+	return res
+}
+
+// =============================================================================
+//  Verify VISA CAVV with key pair of CAVV Key Indicator
+// =============================================================================
+func (r *KeyRegistry) VerifyVisaCavv(pan string, cavv []byte) (*AuthenticationResult, error) {
+	d, err := ParseVisaCavv(cavv)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.CryptoProvider(CARD_BRAND_VISA, d.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	eci, err := d.ECI()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := VerifyVisaCavvWithProvider(pan, cavv, p)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthenticationResult(CARD_BRAND_VISA, d.KeyID, eci, ok), nil
+}
+
+// =============================================================================
+//  Verify Master Card SPA AAV (HMAC-SHA1) with secret key of BIN Key
+//  Identifier
+// =============================================================================
+func (r *KeyRegistry) VerifyMasterCardAAV(pan, merchName string, aav []byte) (*AuthenticationResult, error) {
+	d, err := ParseMasterCardAAV(aav)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.MacProvider(CARD_BRAND_MASTERCARD, d.BINKeyID)
+	if err != nil {
+		return nil, err
+	}
+	eci, err := d.ECI()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := VerifyMasterCardAAVWithProvider(pan, merchName, aav, p)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthenticationResult(CARD_BRAND_MASTERCARD, d.BINKeyID, eci, ok), nil
+}
+
+// =============================================================================
+//  Verify Master Card SPA AAV (HMAC-SHA1): AAV is recalculated from its
+//  fields and merchant name, MAC is compared in constant time
+// =============================================================================
+func VerifyMasterCardAAVWithProvider(pan, merchName string, aav []byte, mp MacProvider) (bool, error) {
+	d, err := ParseMasterCardAAV(aav)
+	if err != nil {
+		return false, err
+	}
+	exp, err := GenerateMasterCardAAVWithProvider(MC_HMAC_SHA1, pan, d.ControlByte, merchName,
+		d.ACSID, d.AuthMethod, d.BINKeyID, d.TSN, nil, nil, mp, nil)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(exp, aav) == 1, nil
+}
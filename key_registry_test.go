@@ -0,0 +1,44 @@
+package gocavv
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// =============================================================================
+// Test verification of authentication values routed by brand & key indicator
+// =============================================================================
+func TestKeyRegistry(t *testing.T) {
+	mcKey, _ := hex.DecodeString("0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B")
+
+	reg := NewKeyRegistry()
+	reg.AddCryptoProvider(CARD_BRAND_VISA, 1, NewSoftwareCryptoProvider(keyAV, keyBV))
+	reg.AddMacProvider(CARD_BRAND_MASTERCARD, TEST_MC_BIN_KEY_ID, NewSoftwareMacProvider(mcKey))
+
+	// VISA
+	cavv, _ := GenerateVisaCavv(TEST_V_PAN_16, TEST_V_I_ATN, VISA_ARC_ATTEMPTS, 98, 1, keyAV, keyBV)
+	res, err := reg.VerifyVisaCavv(TEST_V_PAN_16, cavv)
+	if err != nil || !res.Valid || res.Status != EMV3DS_TRANS_STATUS_A || res.ECI != ECI_VISA_ATTEMPTS {
+		t.Fatalf("[REGISTRY]: Failed to verify VISA CAVV: %+v (%v)\n", res, err)
+	}
+	cavv, _ = GenerateVisaCavv(TEST_V_PAN_16, TEST_V_I_ATN, VISA_ARC_ATTEMPTS, 98, 2, keyAV, keyBV)
+	if _, err := reg.VerifyVisaCavv(TEST_V_PAN_16, cavv); err == nil {
+		t.Fatalf("[REGISTRY]: Verified VISA CAVV with unknown key indicator\n")
+	}
+
+	// Master Card
+	pan := "5432109876543210"
+	aav, _ := GenerateMasterCardAAV(MC_HMAC_SHA1, pan, TEST_MC_CONTOL_BYTE, TEST_MC_MERCH_NAME,
+		TEST_MC_ACS_ID, TEST_MC_ACS_AUTH_METHOD, TEST_MC_BIN_KEY_ID, TEST_MC_TSN, nil, nil, mcKey, nil)
+	res, err = reg.VerifyMasterCardAAV(pan, TEST_MC_MERCH_NAME, aav)
+	if err != nil || !res.Valid || res.Status != EMV3DS_TRANS_STATUS_Y || res.ECI != ECI_MC_AUTHENTICATED {
+		t.Fatalf("[REGISTRY]: Failed to verify MasterCard AAV: %+v (%v)\n", res, err)
+	}
+	if res, _ = reg.VerifyMasterCardAAV(pan, "Another Merchant", aav); res == nil || res.Valid {
+		t.Fatalf("[REGISTRY]: Verified MasterCard AAV for another merchant\n")
+	}
+
+	if _, err := reg.MacProvider(CARD_BRAND_VISA, TEST_MC_BIN_KEY_ID); err == nil {
+		t.Fatalf("[REGISTRY]: Found MasterCard key for another brand\n")
+	}
+}
@@ -0,0 +1,79 @@
+package gocavv
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+//  Test key material is not printed by fmt
+// =============================================================================
+func TestKey_Redacted(t *testing.T) {
+	key, _ := hex.DecodeString(TEST_KEY_CVK)
+	k, err := NewSecretKey(key)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to create secret key: %s\n", err)
+	}
+	cp, _ := k.CryptoProvider()
+	mp, _ := k.MacProvider()
+	c := &KeyComponent{Value: KeyBytes(key), KCV: TEST_KEY_CVK_KCV}
+	kb := &TR31KeyBlock{Key: KeyBytes(key)}
+
+	for _, v := range []interface{}{k, *k, cp, mp, c, kb, KeyBytes(key)} {
+		for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%x", "%X"} {
+			out := fmt.Sprintf(verb, v)
+			if strings.Contains(strings.ToUpper(out), "0123456789ABCDEF") || strings.Contains(out, "[1 35 69") {
+				t.Fatalf("[KEY]: Key material printed with %s: %s\n", verb, out)
+			}
+		}
+	}
+	if s := k.String(); !strings.Contains(s, TEST_KEY_CVK_KCV) {
+		t.Fatalf("[KEY]: Invalid secret key description: %s\n", s)
+	}
+}
+
+// =============================================================================
+//  Test key destroy wipes material shared with providers
+// =============================================================================
+func TestKey_Destroy(t *testing.T) {
+	key, _ := hex.DecodeString(TEST_KEY_CVK)
+	k, err := NewSecretKey(key)
+	if err != nil {
+		t.Fatalf("[KEY]: Failed to create secret key: %s\n", err)
+	}
+	keyA, _, _ := k.CVK()
+	cp, _ := k.CryptoProvider()
+	mp, _ := k.MacProvider()
+	if _, err := cp.GenerateCVV2(TEST_V_PAN_16, TEST_V_S_ATN[12:], TEST_S_SERVICE_CODE); err != nil {
+		t.Fatalf("[KEY]: Failed to generate CVV2 with key: %s\n", err)
+	}
+
+	k.Destroy()
+	for i := range key {
+		if key[i] != 0 {
+			t.Fatalf("[KEY]: Key material is not wiped: %d\n", i)
+		}
+	}
+	if keyA[0] != 0 {
+		t.Fatalf("[KEY]: CVK A is not wiped\n")
+	}
+	if _, _, err := k.CVK(); err == nil {
+		t.Fatalf("[KEY]: Destroyed key used as CVK\n")
+	}
+	if _, err := k.MacProvider(); err == nil {
+		t.Fatalf("[KEY]: Destroyed key used as HMAC key\n")
+	}
+	// Providers handed out before destroy must not fall back to zero key
+	if _, err := cp.GenerateCVV2(TEST_V_PAN_16, TEST_V_S_ATN[12:], TEST_S_SERVICE_CODE); err == nil {
+		t.Fatalf("[KEY]: Destroyed key used by crypto provider\n")
+	}
+	if _, err := cp.VerifyCVV2(TEST_V_PAN_16, TEST_V_S_ATN[12:], TEST_S_SERVICE_CODE, 0); err == nil {
+		t.Fatalf("[KEY]: Destroyed key used by crypto provider to verify\n")
+	}
+	if _, err := mp.HMAC(crypto.SHA256, []byte(TEST_MC_MERCH_NAME)); err == nil {
+		t.Fatalf("[KEY]: Destroyed key used by MAC provider\n")
+	}
+}
@@ -1,7 +1,7 @@
 package gocavv
 
 import (
-	"crypto/hmac"
+	"crypto"
 	"crypto/sha1"
 	"encoding/binary"
 	"fmt"
@@ -188,6 +188,26 @@ func GenerateMasterCardAAV(macType MasterCardMacType, /* MAC type */
 	scode *string,
 	keyA, keyB []byte) ([]byte, error) {
 
+	return GenerateMasterCardAAVWithProvider(macType, pan, cb, merchName, acsID, authMethod, keyID, tsn,
+		atn, scode, NewSoftwareMacProvider(keyA), NewSoftwareCryptoProvider(keyA, keyB))
+}
+// =============================================================================
+//  Generate Master Card AAV with MAC calculated by providers (software, HSM):
+//  mp is used for HMAC-SHA1, cp for CVC2
+// =============================================================================
+func GenerateMasterCardAAVWithProvider(macType MasterCardMacType, /* MAC type */
+	pan string,       /* Primary Account Number (PAN) */
+	cb uint8,         /* Control Byte (Format Version Number)*/
+	merchName string, /* Merchant name*/
+	acsID uint8,      /* ACS Identifier */
+	authMethod uint8, /* ACS Authentication Method */
+	keyID uint8,      /* BIN Key Identifier */
+	tsn uint32,       /* Transaction Sequence Number */
+	atn *string,
+	scode *string,
+	mp MacProvider,
+	cp CryptoProvider) ([]byte, error) {
+
 	if keyID > 0x0F {
 		return nil, fmt.Errorf("Invalid BIN Key Identifier, more than 0x0F: %d", keyID)
 	}
@@ -207,6 +227,8 @@ func GenerateMasterCardAAV(macType MasterCardMacType, /* MAC type */
 	if err != nil {
 		return nil, err
 	}
+	// Wipe MAC input (PAN) on return
+	defer zeroBytes(mac)
 
 	// create AAV destination buffer (20 bytes)
 	aav := make([]byte, 20)
@@ -215,20 +237,59 @@ func GenerateMasterCardAAV(macType MasterCardMacType, /* MAC type */
 
 	if macType == MC_HMAC_SHA1 {
 		// Calculate HMAC-SHA1 hash
-		h := hmac.New(sha1.New, keyA)
-		h.Write(mac)
+		h, err := mp.HMAC(crypto.SHA1, mac)
+		if err != nil {
+			return nil, err
+		}
 		// Set last 5 bytes to result AAV buffer
-		copy(aav[15:], h.Sum(nil)[:5])
+		copy(aav[15:], h[:5])
+		zeroBytes(h)
 
 	} else if macType == MC_CVC2 {
+		if atn == nil || scode == nil {
+			return nil, fmt.Errorf("ATN and Service Code are required for CVC2 mac")
+		}
 		// Generate CVC2
-		cvc2, err := generateCVV2(pan, *atn, *scode, keyA, keyB)
+		cvc2, err := cp.GenerateCVV2(pan, *atn, *scode)
 		if err != nil {
 			return nil, err
 		}
 		// Add CVC2
-		copy(aav[15:], dec2bcd(uint64(cvc2)))
+		copy(aav[15:], dec2bcdPadded(uint64(cvc2), 2))
 	}
 
 	return aav, nil
 }
+// =============================================================================
+//  Master Card SPA AAV fields (20 bytes)
+// =============================================================================
+type MasterCardAAVData struct {
+	ControlByte      uint8
+	MerchantNameHash []byte /* 8 bytes */
+	ACSID            uint8
+	AuthMethod       uint8
+	BINKeyID         uint8
+	TSN              uint32
+	MAC              []byte /* 5 bytes */
+}
+
+// =============================================================================
+//  Decode Master Card SPA AAV (20 bytes)
+// =============================================================================
+func ParseMasterCardAAV(aav []byte) (*MasterCardAAVData, error) {
+	if len(aav) != 20 {
+		return nil, fmt.Errorf("Invalid AAV length: %d, expected: 20", len(aav))
+	}
+	if aav[0] != MC_AAV_CB_AUTHENTICATED && aav[0] != MC_AAV_CB_ATTEMPTS {
+		return nil, fmt.Errorf("Invalid AAV control byte: 0x%02X", aav[0])
+	}
+	return &MasterCardAAVData{
+		ControlByte:      aav[0],
+		MerchantNameHash: append([]byte(nil), aav[1:9]...),
+		ACSID:            aav[9],
+		AuthMethod:       aav[10] >> 4,
+		BINKeyID:         aav[10] & 0x0F,
+		TSN:              binary.BigEndian.Uint32(aav[11:15]),
+		MAC:              append([]byte(nil), aav[15:20]...),
+	}, nil
+}
@@ -1,16 +1,18 @@
 package gocavv
 
 import (
+	"crypto"
 	"crypto/sha256"
 	"math"
 	"fmt"
 	"encoding/binary"
-	"crypto/hmac"
 	"bytes"
 )
 
 const (
 	MC_IAV_AMOUNT_MAX_EXPLICIT   float64 = 14000
+	// IAV control byte, transaction status is not encoded in the IAV
+	MC_IAV_CB                    uint8   = 0xC6
 )
 // =============================================================================
 //  Helper function to create merchant name SHA-1 hash
@@ -63,6 +65,15 @@ func GenerateMasterCardIAV(pan string, /* Primary Account Number (PAN) */
 	merchName string, /* Merchant name*/
 	amount float64, currency uint16, dsn uint32, secret []byte ) ([]byte, error) {
 
+	return GenerateMasterCardIAVWithProvider(pan, merchName, amount, currency, dsn, NewSoftwareMacProvider(secret))
+}
+// =============================================================================
+//  Generate Master Card IAV with HMAC-SHA256 calculated by provider (software, HSM)
+// =============================================================================
+func GenerateMasterCardIAVWithProvider(pan string, /* Primary Account Number (PAN) */
+	merchName string, /* Merchant name*/
+	amount float64, currency uint16, dsn uint32, mp MacProvider) ([]byte, error) {
+
 	// Create MAC slice
 	mac := make([]byte, 22)
 	// Create mac buffer
@@ -70,17 +81,20 @@ func GenerateMasterCardIAV(pan string, /* Primary Account Number (PAN) */
 		return nil, err
 	}
 	// Calculate HMAC-SHA256
-	h := hmac.New(sha256.New, secret)
-	h.Write(mac)
-	bs := h.Sum(nil)
-	// Clear slice
-	mac = nil
+	bs, err := mp.HMAC(crypto.SHA256, mac)
+	if err != nil {
+		zeroBytes(mac)
+		return nil, err
+	}
+	// Wipe MAC input & output
+	zeroBytes(mac)
+	defer zeroBytes(bs)
 	// Build output buffer 28 bytes
 	iav := bytes.Repeat([]byte{0}, 28)
-	iav[0] = 0xC6
+	iav[0] = MC_IAV_CB
 	iav[1] = 0x04
 	// Copy only first 4 bytes from mac iav
 	copy(iav[2:], bs[:4])
 	// Return buffer
 	return iav, nil
-}
\ No newline at end of file
+}
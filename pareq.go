@@ -0,0 +1,290 @@
+package gocavv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+/*
+PAReq message 3-D Secure 1.0.2:
+------------------------------------------------------------------------------------------------------------------------
+|  Element                  |  Description                                                          |   Format       |
+------------------------------------------------------------------------------------------------------------------------
+|  Merchant/acqBIN          |  Acquirer BIN                                                         |   1-11 digits  |
+|  Merchant/merID           |  Merchant identifier assigned by acquirer                             |   1-24 chars   |
+|  Merchant/name            |  Merchant name                                                        |   max 25 chars |
+|  Merchant/country         |  Merchant country code (ISO 3166-1 numeric)                           |   3 digits     |
+|  Merchant/url             |  Merchant URL                                                         |   max 2048     |
+------------------------------------------------------------------------------------------------------------------------
+|  Purchase/xid             |  Transaction identifier (20 bytes, base64)                            |   28 chars     |
+|  Purchase/date            |  Purchase date & time GMT (YYYYMMDD HH:MM:SS)                         |   17 chars     |
+|  Purchase/amount          |  Purchase amount displayed to cardholder                              |   max 20 chars |
+|  Purchase/purchAmount     |  Purchase amount in minor units                                       |   max 12 digits|
+|  Purchase/currency        |  Currency code (ISO 4217 numeric)                                     |   3 digits     |
+|  Purchase/exponent        |  Currency minor units exponent                                        |   1 digit      |
+|  Purchase/desc            |  Purchase description (optional)                                      |   max 125 chars|
+|  Purchase/Recur           |  Recurring payment frequency & end date (optional)                    |                |
+|  Purchase/install         |  Maximum number of installments (optional)                            |   1-4 digits   |
+------------------------------------------------------------------------------------------------------------------------
+|  CH/acctID                |  Account identifier from VERes                                        |   1-28 chars   |
+|  CH/expiry                |  Card expiry date (YYMM)                                              |   4 digits     |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	TDS_DATE_FORMAT       string = "20060102 15:04:05"
+	TDS_RECUR_DATE_FORMAT string = "20060102"
+)
+
+// =============================================================================
+//  PAReq merchant data
+// =============================================================================
+type PAReqMerchant struct {
+	AcqBIN  string
+	MerID   string
+	Name    string
+	Country string
+	URL     string
+}
+
+// =============================================================================
+//  PAReq recurring payment data
+// =============================================================================
+type PAReqRecur struct {
+	Frequency uint
+	EndRecur  time.Time
+}
+
+// =============================================================================
+//  PAReq purchase data
+// =============================================================================
+type PAReqPurchase struct {
+	XID         string
+	Date        time.Time
+	Amount      string
+	PurchAmount uint64
+	Currency    string
+	Exponent    uint8
+	Desc        string
+	Recur       *PAReqRecur
+	Install     uint
+}
+
+// =============================================================================
+//  PAReq cardholder data
+// =============================================================================
+type PAReqCH struct {
+	AcctID string
+	Expiry string
+}
+
+// =============================================================================
+//  PAReq (Payer Authentication Request) 3-D Secure 1.0.2
+// =============================================================================
+type PAReq struct {
+	MessageID  string
+	Merchant   PAReqMerchant
+	Purchase   PAReqPurchase
+	CH         PAReqCH
+	Extensions []ThreeDSExtension
+}
+
+// =============================================================================
+//  Validate PAReq fields against 3-D Secure 1.0.2 schema
+// =============================================================================
+func (p *PAReq) Validate() error {
+	if len(p.MessageID) == 0 || len(p.MessageID) > 128 {
+		return fmt.Errorf("Invalid PAReq message id length: %d", len(p.MessageID))
+	}
+	m := &p.Merchant
+	if !isDigits(m.AcqBIN) || len(m.AcqBIN) == 0 || len(m.AcqBIN) > 11 {
+		return fmt.Errorf("Invalid PAReq acquirer BIN: %q", m.AcqBIN)
+	}
+	if len(m.MerID) == 0 || len(m.MerID) > 24 {
+		return fmt.Errorf("Invalid PAReq merchant id length: %d", len(m.MerID))
+	}
+	if len([]rune(m.Name)) > 25 {
+		return fmt.Errorf("Invalid PAReq merchant name length: %d", len([]rune(m.Name)))
+	}
+	if !isDigits(m.Country) || len(m.Country) != 3 {
+		return fmt.Errorf("Invalid PAReq merchant country: %q", m.Country)
+	}
+	if len(m.URL) == 0 || len(m.URL) > 2048 {
+		return fmt.Errorf("Invalid PAReq merchant url length: %d", len(m.URL))
+	}
+	pu := &p.Purchase
+	if _, err := DecodeXID(pu.XID); err != nil {
+		return fmt.Errorf("Invalid PAReq xid: %q", pu.XID)
+	}
+	if pu.Date.IsZero() {
+		return fmt.Errorf("PAReq purchase date is missing")
+	}
+	if len(pu.Amount) == 0 || len(pu.Amount) > 20 {
+		return fmt.Errorf("Invalid PAReq amount length: %d", len(pu.Amount))
+	}
+	if pu.PurchAmount > 999999999999 {
+		return fmt.Errorf("Invalid PAReq purchase amount: %d", pu.PurchAmount)
+	}
+	if !isDigits(pu.Currency) || len(pu.Currency) != 3 {
+		return fmt.Errorf("Invalid PAReq currency: %q", pu.Currency)
+	}
+	if pu.Exponent > 9 {
+		return fmt.Errorf("Invalid PAReq currency exponent: %d", pu.Exponent)
+	}
+	if len([]rune(pu.Desc)) > 125 {
+		return fmt.Errorf("Invalid PAReq description length: %d", len([]rune(pu.Desc)))
+	}
+	if pu.Recur != nil && (pu.Recur.Frequency == 0 || pu.Recur.Frequency > 9999 || pu.Recur.EndRecur.IsZero()) {
+		return fmt.Errorf("Invalid PAReq recurring data: frequency %d", pu.Recur.Frequency)
+	}
+	if pu.Install > 9999 {
+		return fmt.Errorf("Invalid PAReq installments: %d", pu.Install)
+	}
+	if len(p.CH.AcctID) == 0 || len(p.CH.AcctID) > 28 {
+		return fmt.Errorf("Invalid PAReq account id length: %d", len(p.CH.AcctID))
+	}
+	if !isDigits(p.CH.Expiry) || len(p.CH.Expiry) != 4 {
+		return fmt.Errorf("Invalid PAReq card expiry: %q", p.CH.Expiry)
+	}
+	return validateThreeDSExtensions(p.Extensions)
+}
+
+// =============================================================================
+//  Marshal PAReq to 3-D Secure 1.0.2 XML message
+// =============================================================================
+func (p *PAReq) Marshal() ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	doc, msg := newThreeDSecureDocument(p.MessageID)
+	pq := msg.CreateElement("PAReq")
+	pq.CreateElement("version").SetText(TDS_MSG_VER_1)
+
+	merch := pq.CreateElement("Merchant")
+	merch.CreateElement("acqBIN").SetText(p.Merchant.AcqBIN)
+	merch.CreateElement("merID").SetText(p.Merchant.MerID)
+	merch.CreateElement("name").SetText(p.Merchant.Name)
+	merch.CreateElement("country").SetText(p.Merchant.Country)
+	merch.CreateElement("url").SetText(p.Merchant.URL)
+
+	pu := pq.CreateElement("Purchase")
+	pu.CreateElement("xid").SetText(p.Purchase.XID)
+	pu.CreateElement("date").SetText(p.Purchase.Date.UTC().Format(TDS_DATE_FORMAT))
+	pu.CreateElement("amount").SetText(p.Purchase.Amount)
+	pu.CreateElement("purchAmount").SetText(strconv.FormatUint(p.Purchase.PurchAmount, 10))
+	pu.CreateElement("currency").SetText(p.Purchase.Currency)
+	pu.CreateElement("exponent").SetText(strconv.Itoa(int(p.Purchase.Exponent)))
+	if p.Purchase.Desc != "" {
+		pu.CreateElement("desc").SetText(p.Purchase.Desc)
+	}
+	if p.Purchase.Recur != nil {
+		rc := pu.CreateElement("Recur")
+		rc.CreateElement("frequency").SetText(strconv.Itoa(int(p.Purchase.Recur.Frequency)))
+		rc.CreateElement("endRecur").SetText(p.Purchase.Recur.EndRecur.Format(TDS_RECUR_DATE_FORMAT))
+	}
+	if p.Purchase.Install > 0 {
+		pu.CreateElement("install").SetText(strconv.Itoa(int(p.Purchase.Install)))
+	}
+
+	ch := pq.CreateElement("CH")
+	ch.CreateElement("acctID").SetText(p.CH.AcctID)
+	ch.CreateElement("expiry").SetText(p.CH.Expiry)
+
+	if err := marshalThreeDSExtensions(pq, p.Extensions); err != nil {
+		return nil, err
+	}
+	return doc.WriteToBytes()
+}
+
+// =============================================================================
+//  Marshal PAReq and package it (deflate & base64) for PaReq form field
+// =============================================================================
+func (p *PAReq) Package() (string, error) {
+	b, err := p.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return PackThreeDSMessage(b)
+}
+
+// =============================================================================
+//  Parse PAReq 3-D Secure 1.0.2 XML message (ACS side)
+// =============================================================================
+func UnmarshalPAReq(data []byte) (*PAReq, error) {
+	id, pq, err := parseThreeDSecureMessage(data, "PAReq")
+	if err != nil {
+		return nil, err
+	}
+	if v := childText(pq, "version"); v != TDS_MSG_VER_1 {
+		return nil, fmt.Errorf("Unsupported PAReq version: %q", v)
+	}
+	p := &PAReq{
+		MessageID: id,
+		Merchant: PAReqMerchant{
+			AcqBIN:  childText(pq, "Merchant/acqBIN"),
+			MerID:   childText(pq, "Merchant/merID"),
+			Name:    childText(pq, "Merchant/name"),
+			Country: childText(pq, "Merchant/country"),
+			URL:     childText(pq, "Merchant/url"),
+		},
+		Purchase: PAReqPurchase{
+			XID:      childText(pq, "Purchase/xid"),
+			Amount:   childText(pq, "Purchase/amount"),
+			Currency: childText(pq, "Purchase/currency"),
+			Desc:     childText(pq, "Purchase/desc"),
+		},
+		CH: PAReqCH{
+			AcctID: childText(pq, "CH/acctID"),
+			Expiry: childText(pq, "CH/expiry"),
+		},
+	}
+	pu := &p.Purchase
+	if pu.Date, err = time.Parse(TDS_DATE_FORMAT, childText(pq, "Purchase/date")); err != nil {
+		return nil, fmt.Errorf("Invalid PAReq purchase date: %s", err)
+	}
+	if pu.PurchAmount, err = strconv.ParseUint(childText(pq, "Purchase/purchAmount"), 10, 64); err != nil {
+		return nil, fmt.Errorf("Invalid PAReq purchase amount: %s", err)
+	}
+	exp, err := strconv.ParseUint(childText(pq, "Purchase/exponent"), 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid PAReq currency exponent: %s", err)
+	}
+	pu.Exponent = uint8(exp)
+	if pq.FindElement("Purchase/Recur") != nil {
+		pu.Recur = &PAReqRecur{}
+		freq, err := strconv.ParseUint(childText(pq, "Purchase/Recur/frequency"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PAReq recurring frequency: %s", err)
+		}
+		pu.Recur.Frequency = uint(freq)
+		if pu.Recur.EndRecur, err = time.Parse(TDS_RECUR_DATE_FORMAT, childText(pq, "Purchase/Recur/endRecur")); err != nil {
+			return nil, fmt.Errorf("Invalid PAReq recurring end date: %s", err)
+		}
+	}
+	if s := childText(pq, "Purchase/install"); s != "" {
+		install, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PAReq installments: %s", err)
+		}
+		pu.Install = uint(install)
+	}
+	if p.Extensions, err = unmarshalThreeDSExtensions(pq); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// =============================================================================
+//  Unpackage (base64 & inflate) and parse PAReq from PaReq form field
+// =============================================================================
+func UnpackPAReq(b64 string) (*PAReq, error) {
+	data, err := UnpackThreeDSMessage(b64)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalPAReq(data)
+}
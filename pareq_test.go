@@ -0,0 +1,70 @@
+package gocavv
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	// PAReq packaged by merchant plug-in (Mir acquirer)
+	TEST_PAREQ_B64 string = "eJxVUltOwkAU3UrTXwLTKRWQXIZUHgEN0CD44MeU9sZWbQvTVoEvY9yD2zDxQz90D2Uh7sEZwFeaJvece3PO3DMD9UVwo9wij/0orKm0oKkKhk7k+uFlTR2P2vmKWmcw8jhi8xidlCODHsaxfYmK79ZUyxzivHq6mpTvBlfjuLFfmnYN9DA9zJsqg02bwc6ACf2CDuQbCiXueHaYMLCd+UG3z3Rdo8WKRqkBZEdBgLzbZFp5+wHZYgjtAJk5UCzropVvDHq91rDRArKhwYnSMOFLVjKKQL4BpPyGeUkyqxKSvWfP2Yv4X7O37KOwvl8/ApF9IL+HslJZxUJv4buMju4CLT1ZRaMgKQ4tz5vRc77yVq1JrwZEToBrJ8jEChWtpO8rtFwt7lWpWHjDgx3IgzCqaWK5bQ0zaWH+afwlQOTNxXUsWcUQrR8EuJhFIYoJof1Tg4uxwz4fnpQhuspBFF0LY0kB+V2k0ZFZO4lI0DzrT5tH7ek0dLs53WiPE547ykUDveOVZPqbIWnli+z0Pbr1kgCIlCG7yyW79yCqf+/kC+2Kzfw="
+)
+
+// =============================================================================
+// Test PAReq unpackaging & parsing
+// =============================================================================
+func TestPAReqUnpack(t *testing.T) {
+	p, err := UnpackPAReq(TEST_PAREQ_B64)
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to unpack PAReq: %s\n", err)
+	}
+	if p.MessageID != "PAReq:WzZ7wOjUsC96bI4eheuJ-A" || p.Merchant.AcqBIN != "2201380114" || p.Merchant.Name != "AO PP_E-COMMERCE" {
+		t.Fatalf("[3DS]: Invalid PAReq merchant: %+v\n", p.Merchant)
+	}
+	if p.Purchase.XID != "1Twm0uVzoTmt3RPhhp1YrzhzEZM=" || p.Purchase.PurchAmount != 100 || p.Purchase.Currency != "840" || p.Purchase.Exponent != 2 {
+		t.Fatalf("[3DS]: Invalid PAReq purchase: %+v\n", p.Purchase)
+	}
+	if !p.Purchase.Date.Equal(time.Date(2018, 6, 29, 17, 35, 12, 0, time.UTC)) {
+		t.Fatalf("[3DS]: Invalid PAReq purchase date: %s\n", p.Purchase.Date)
+	}
+	if p.CH.AcctID != "AXNbDKFbbndI+24FUtr+K+oO2Hh6" || p.CH.Expiry != "2512" {
+		t.Fatalf("[3DS]: Invalid PAReq cardholder: %+v\n", p.CH)
+	}
+}
+// =============================================================================
+// Test PAReq marshalling & packaging round trip
+// =============================================================================
+func TestPAReqPackage(t *testing.T) {
+	p, _ := UnpackPAReq(TEST_PAREQ_B64)
+	p.Purchase.Recur = &PAReqRecur{Frequency: 30, EndRecur: time.Date(2019, 6, 29, 0, 0, 0, 0, time.UTC)}
+	p.Purchase.Install = 12
+
+	b64, err := p.Package()
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to package PAReq: %s\n", err)
+	}
+	r, err := UnpackPAReq(b64)
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to unpack PAReq: %s\n", err)
+	}
+	if r.Purchase.Desc != p.Purchase.Desc || r.Merchant.URL != p.Merchant.URL || r.Purchase.Install != 12 ||
+		r.Purchase.Recur == nil || r.Purchase.Recur.Frequency != 30 || !r.Purchase.Recur.EndRecur.Equal(p.Purchase.Recur.EndRecur) {
+		t.Fatalf("[3DS]: Invalid PAReq after round trip: %+v\n", r.Purchase)
+	}
+}
+// =============================================================================
+// Test PAReq validation
+// =============================================================================
+func TestPAReqValidate(t *testing.T) {
+	p, _ := UnpackPAReq(TEST_PAREQ_B64)
+	p.Purchase.XID = "MTIz"
+	if _, err := p.Marshal(); err == nil {
+		t.Fatalf("[3DS]: Marshalled PAReq with invalid xid\n")
+	}
+
+	p, _ = UnpackPAReq(TEST_PAREQ_B64)
+	p.CH.Expiry = "25122"
+	if _, err := p.Marshal(); err == nil {
+		t.Fatalf("[3DS]: Marshalled PAReq with invalid expiry\n")
+	}
+}
@@ -0,0 +1,257 @@
+package gocavv
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+/*
+PARes message 3-D Secure 1.0.2:
+------------------------------------------------------------------------------------------------------------------------
+|  Element                  |  Description                                                          |   Format       |
+------------------------------------------------------------------------------------------------------------------------
+|  PARes@id                 |  Identifier referenced by the message signature                       |   string       |
+------------------------------------------------------------------------------------------------------------------------
+|  Merchant/acqBIN          |  Acquirer BIN from PAReq                                              |   1-11 digits  |
+|  Merchant/merID           |  Merchant identifier from PAReq                                       |   1-24 chars   |
+------------------------------------------------------------------------------------------------------------------------
+|  Purchase/xid             |  Transaction identifier from PAReq                                    |   28 chars     |
+|  Purchase/date            |  Purchase date from PAReq                                             |   17 chars     |
+|  Purchase/purchAmount     |  Purchase amount from PAReq                                           |   max 12 digits|
+|  Purchase/currency        |  Currency code from PAReq                                             |   3 digits     |
+|  Purchase/exponent        |  Currency exponent from PAReq                                         |   1 digit      |
+------------------------------------------------------------------------------------------------------------------------
+|  pan                      |  Masked PAN (last 4 digits, zero filled)                              |   13-19 digits |
+------------------------------------------------------------------------------------------------------------------------
+|  TX/time                  |  Time of PARes creation GMT (YYYYMMDD HH:MM:SS)                       |   17 chars     |
+|  TX/status                |  Y - authenticated, N - failed, U - not performed, A - attempt        |   1 char       |
+|  TX/cavv                  |  CAVV / AAV (20 bytes, base64) for status Y & A                       |   28 chars     |
+|  TX/eci                   |  Electronic Commerce Indicator for status Y & A                       |   2 digits     |
+|  TX/cavvAlgorithm         |  0 - HMAC, 1 - CVV, 2 - CVV with ATN, 3 - MasterCard SPA              |   1 digit      |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	TDS_TX_STATUS_Y string = "Y"
+	TDS_TX_STATUS_N string = "N"
+	TDS_TX_STATUS_U string = "U"
+	TDS_TX_STATUS_A string = "A"
+
+	TDS_CAVV_ALG_HMAC     uint8 = 0
+	TDS_CAVV_ALG_CVV      uint8 = 1
+	TDS_CAVV_ALG_CVV_ATN  uint8 = 2
+	TDS_CAVV_ALG_MC_SPA   uint8 = 3
+
+	// Master Card AAV control byte
+	MC_AAV_CB_AUTHENTICATED uint8 = 0x8C
+	MC_AAV_CB_ATTEMPTS      uint8 = 0x86
+)
+
+// =============================================================================
+//  PARes merchant data echoed from PAReq
+// =============================================================================
+type PAResMerchant struct {
+	AcqBIN string
+	MerID  string
+}
+
+// =============================================================================
+//  PARes purchase data echoed from PAReq
+// =============================================================================
+type PAResPurchase struct {
+	XID         string
+	Date        time.Time
+	PurchAmount uint64
+	Currency    string
+	Exponent    uint8
+}
+
+// =============================================================================
+//  PARes transaction result
+// =============================================================================
+type PAResTX struct {
+	Time          time.Time
+	Status        string
+	CAVV          []byte
+	ECI           string
+	CAVVAlgorithm uint8
+}
+
+// =============================================================================
+//  PARes (Payer Authentication Response) 3-D Secure 1.0.2
+// =============================================================================
+type PARes struct {
+	MessageID  string
+	ID         string
+	Version    string
+	Merchant   PAResMerchant
+	Purchase   PAResPurchase
+	PAN        string
+	TX         PAResTX
+	Extensions []ThreeDSExtension
+	// Signer certificate, set when signature was verified
+	Signer *x509.Certificate
+}
+
+// =============================================================================
+//  Unpackage (base64 & inflate) and parse PARes from PaRes form field, the
+//  CAVV algorithm & ECI are checked for card brand (unless brand is unknown)
+// =============================================================================
+func PaResUnMarshalMessage(paresB64 string, brand CardBrand) (*PARes, error) {
+	data, err := UnpackThreeDSMessage(paresB64)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalPARes(data, brand)
+}
+
+// =============================================================================
+//  Unpackage PARes from PaRes form field, verify message signature against
+//  trust store and parse PARes
+// =============================================================================
+func PaResUnMarshalVerifiedMessage(paresB64 string, brand CardBrand, ts *TrustStore) (*PARes, error) {
+	data, err := UnpackThreeDSMessage(paresB64)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := VerifyPAResSignature(data, ts)
+	if err != nil {
+		return nil, err
+	}
+	r, err := UnmarshalPARes(data, brand)
+	if err != nil {
+		return nil, err
+	}
+	r.Signer = signer
+	return r, nil
+}
+
+// =============================================================================
+//  Parse PARes 3-D Secure 1.0.2 XML message
+// =============================================================================
+func UnmarshalPARes(data []byte, brand CardBrand) (*PARes, error) {
+	id, pr, err := parseThreeDSecureMessage(data, "PARes")
+	if err != nil {
+		return nil, err
+	}
+	r := &PARes{
+		MessageID: id,
+		ID:        pr.SelectAttrValue("id", ""),
+		Version:   childText(pr, "version"),
+		Merchant: PAResMerchant{
+			AcqBIN: childText(pr, "Merchant/acqBIN"),
+			MerID:  childText(pr, "Merchant/merID"),
+		},
+		Purchase: PAResPurchase{
+			XID:      childText(pr, "Purchase/xid"),
+			Currency: childText(pr, "Purchase/currency"),
+		},
+		PAN: childText(pr, "pan"),
+		TX: PAResTX{
+			Status: childText(pr, "TX/status"),
+			ECI:    childText(pr, "TX/eci"),
+		},
+	}
+	pu := &r.Purchase
+	if pu.Date, err = time.Parse(TDS_DATE_FORMAT, childText(pr, "Purchase/date")); err != nil {
+		return nil, fmt.Errorf("Invalid PARes purchase date: %s", err)
+	}
+	if pu.PurchAmount, err = strconv.ParseUint(childText(pr, "Purchase/purchAmount"), 10, 64); err != nil {
+		return nil, fmt.Errorf("Invalid PARes purchase amount: %s", err)
+	}
+	exp, err := strconv.ParseUint(childText(pr, "Purchase/exponent"), 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid PARes currency exponent: %s", err)
+	}
+	pu.Exponent = uint8(exp)
+
+	if r.TX.Time, err = time.Parse(TDS_DATE_FORMAT, childText(pr, "TX/time")); err != nil {
+		return nil, fmt.Errorf("Invalid PARes transaction time: %s", err)
+	}
+	if s := childText(pr, "TX/cavv"); s != "" {
+		if r.TX.CAVV, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("Invalid PARes CAVV encoding: %s", err)
+		}
+	}
+	if s := childText(pr, "TX/cavvAlgorithm"); s != "" {
+		alg, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PARes CAVV algorithm: %q", s)
+		}
+		r.TX.CAVVAlgorithm = uint8(alg)
+	}
+	if r.Extensions, err = unmarshalThreeDSExtensions(pr); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(brand); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  Validate PARes fields, for known card brand the CAVV algorithm, ECI and
+//  CAVV / AAV leading byte must match transaction status
+// =============================================================================
+func (r *PARes) Validate(brand CardBrand) error {
+	if r.Version != TDS_MSG_VER_1 {
+		return fmt.Errorf("Unsupported PARes version: %q", r.Version)
+	}
+	if _, err := DecodeXID(r.Purchase.XID); err != nil {
+		return fmt.Errorf("Invalid PARes xid: %q", r.Purchase.XID)
+	}
+	if !isDigits(r.PAN) || len(r.PAN) < 13 || len(r.PAN) > 19 {
+		return fmt.Errorf("Invalid PARes masked PAN length: %d", len(r.PAN))
+	}
+	switch r.TX.Status {
+	case TDS_TX_STATUS_Y, TDS_TX_STATUS_A:
+		if len(r.TX.CAVV) != 20 {
+			return fmt.Errorf("Invalid PARes CAVV length: %d, expected: 20", len(r.TX.CAVV))
+		}
+		if !isDigits(r.TX.ECI) || len(r.TX.ECI) != 2 {
+			return fmt.Errorf("Invalid PARes ECI: %q", r.TX.ECI)
+		}
+	case TDS_TX_STATUS_N, TDS_TX_STATUS_U:
+		if len(r.TX.CAVV) != 0 {
+			return fmt.Errorf("PARes with status %s must not contain CAVV", r.TX.Status)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Invalid PARes transaction status: %q", r.TX.Status)
+	}
+
+	if brand != CARD_BRAND_UNKNOWN {
+		if err := CheckECI(brand, r.Version, r.TX.Status, r.TX.ECI); err != nil {
+			return err
+		}
+	}
+
+	switch brand {
+	case CARD_BRAND_VISA:
+		if r.TX.CAVVAlgorithm != TDS_CAVV_ALG_CVV && r.TX.CAVVAlgorithm != TDS_CAVV_ALG_CVV_ATN {
+			return fmt.Errorf("Invalid VISA CAVV algorithm: %d", r.TX.CAVVAlgorithm)
+		}
+		// Authentication Results Code (Table D-2) must match ECI
+		if r.TX.CAVVAlgorithm == TDS_CAVV_ALG_CVV_ATN {
+			if eci, err := VisaECIForARC(r.TX.CAVV[0]); err != nil || eci != r.TX.ECI {
+				return fmt.Errorf("Invalid VISA CAVV Authentication Results Code for status %s: %02X", r.TX.Status, r.TX.CAVV[0])
+			}
+		}
+	case CARD_BRAND_MASTERCARD:
+		if r.TX.CAVVAlgorithm != TDS_CAVV_ALG_MC_SPA {
+			return fmt.Errorf("Invalid MasterCard AAV algorithm: %d", r.TX.CAVVAlgorithm)
+		}
+		// IAV control byte is reported as such (no status, see DeriveECI)
+		eci, err := MasterCardECIForControlByte(r.TX.CAVV[0])
+		if err != nil {
+			return err
+		}
+		if eci != r.TX.ECI {
+			return fmt.Errorf("Invalid MasterCard AAV control byte for status %s: %02X", r.TX.Status, r.TX.CAVV[0])
+		}
+	}
+	return nil
+}
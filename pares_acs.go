@@ -0,0 +1,245 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	// PARes element id referenced by the message signature
+	TDS_PARES_ID string = "PARes1"
+)
+
+// =============================================================================
+//  ACS authentication decision with card brand specific authentication value
+//  input data
+// =============================================================================
+type ACSDecision struct {
+	Status string    /* Transaction status: Y, N, U, A             */
+	Time   time.Time /* PARes creation time, current time if zero */
+
+	// VISA CAVV (CVV with ATN)
+	ATN          uint  /* 16-digit Authentication Tracking Number */
+	SecondFactor uint8 /* Second Factor Authentication Code      */
+	CAVVKeyID    uint8 /* CAVV Key Indicator                     */
+
+	// Master Card AAV (SPA HMAC-SHA1)
+	ACSID      uint8  /* ACS Identifier              */
+	AuthMethod uint8  /* ACS Authentication Method   */
+	BINKeyID   uint8  /* BIN Key Identifier          */
+	TSN        uint32 /* Transaction Sequence Number */
+
+	// Master Card IAV (SPA2 HMAC-SHA256, see MasterCardGenerator)
+	Amount   float64 /* Purchase amount             */
+	Currency uint16  /* ISO 4217 currency code      */
+	DSN      uint32  /* Directory Server Number     */
+
+	Extensions []ThreeDSExtension
+}
+
+// =============================================================================
+//  ACS signing key & certificate chain (starting with signer certificate),
+//  Hash is signature & digest hash function (SHA1 if not set)
+// =============================================================================
+type PAResSigner struct {
+	Key   crypto.Signer
+	Chain []*x509.Certificate
+	Hash  crypto.Hash
+}
+
+// =============================================================================
+//  Build PARes for PAReq (ACS side): merchant & purchase data are echoed, the
+//  CAVV (VISA) or AAV (Master Card) is generated for status Y & A with ECI
+//  and CAVV algorithm of card brand. VISA CAVV is produced by crypto provider,
+//  Master Card AAV by mac provider
+// =============================================================================
+func NewPARes(req *PAReq, pan string, brand CardBrand, d *ACSDecision, cp CryptoProvider, mp MacProvider) (*PARes, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if !isDigits(pan) || len(pan) < 13 || len(pan) > 19 {
+		return nil, fmt.Errorf("Invalid Primary Account Number (PAN) length: %d", len(pan))
+	}
+	r := &PARes{
+		MessageID: req.MessageID,
+		ID:        TDS_PARES_ID,
+		Version:   TDS_MSG_VER_1,
+		Merchant: PAResMerchant{
+			AcqBIN: req.Merchant.AcqBIN,
+			MerID:  req.Merchant.MerID,
+		},
+		Purchase: PAResPurchase{
+			XID:         req.Purchase.XID,
+			Date:        req.Purchase.Date,
+			PurchAmount: req.Purchase.PurchAmount,
+			Currency:    req.Purchase.Currency,
+			Exponent:    req.Purchase.Exponent,
+		},
+		PAN: maskPAN(pan),
+		TX: PAResTX{
+			Time:   d.Time,
+			Status: d.Status,
+		},
+		Extensions: d.Extensions,
+	}
+	if r.TX.Time.IsZero() {
+		r.TX.Time = time.Now()
+	}
+
+	var err error
+
+	switch d.Status {
+	case TDS_TX_STATUS_Y, TDS_TX_STATUS_A:
+	case TDS_TX_STATUS_N, TDS_TX_STATUS_U:
+		return r, nil
+	default:
+		return nil, fmt.Errorf("Invalid PARes transaction status: %q", d.Status)
+	}
+
+	if r.TX.ECI, err = DeriveECI(brand, r.Version, d.Status); err != nil {
+		return nil, err
+	}
+
+	switch brand {
+	case CARD_BRAND_VISA:
+		if cp == nil {
+			return nil, fmt.Errorf("Crypto provider is required for VISA CAVV")
+		}
+		r.TX.CAVVAlgorithm = TDS_CAVV_ALG_CVV_ATN
+		arc, err := VisaAuthResultsCode(d.Status)
+		if err != nil {
+			return nil, err
+		}
+		if r.TX.CAVV, err = GenerateVisaCavvWithProvider(pan, d.ATN, arc, d.SecondFactor, d.CAVVKeyID, cp); err != nil {
+			return nil, err
+		}
+	case CARD_BRAND_MASTERCARD:
+		if mp == nil {
+			return nil, fmt.Errorf("Mac provider is required for MasterCard AAV")
+		}
+		r.TX.CAVVAlgorithm = TDS_CAVV_ALG_MC_SPA
+		cb := MC_AAV_CB_ATTEMPTS
+		if d.Status == TDS_TX_STATUS_Y {
+			cb = MC_AAV_CB_AUTHENTICATED
+		}
+		if r.TX.CAVV, err = GenerateMasterCardAAVWithProvider(MC_HMAC_SHA1, pan, cb, req.Merchant.Name,
+			d.ACSID, d.AuthMethod, d.BINKeyID, d.TSN, nil, nil, mp, nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported card brand: %s", brand)
+	}
+	if err := r.Validate(brand); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  Helper function to mask PAN: last 4 digits, zero filled
+// =============================================================================
+func maskPAN(pan string) string {
+	if len(pan) <= 4 {
+		return pan
+	}
+	return strings.Repeat("0", len(pan)-4) + pan[len(pan)-4:]
+}
+
+// =============================================================================
+//  Marshal PARes to unsigned 3-D Secure 1.0.2 XML message
+// =============================================================================
+func (r *PARes) Marshal() ([]byte, error) {
+	doc, _, _, err := r.document()
+	if err != nil {
+		return nil, err
+	}
+	return doc.WriteToBytes()
+}
+
+// =============================================================================
+//  Marshal PARes to 3-D Secure 1.0.2 XML message signed by ACS
+// =============================================================================
+func (r *PARes) Sign(s *PAResSigner) ([]byte, error) {
+	if s == nil || s.Key == nil {
+		return nil, fmt.Errorf("PARes signing key is missing")
+	}
+	doc, msg, pr, err := r.document()
+	if err != nil {
+		return nil, err
+	}
+	h := s.Hash
+	if h == 0 {
+		h = crypto.SHA1
+	}
+	if err := signXMLElement(msg, pr, s.Key, s.Chain, h); err != nil {
+		return nil, err
+	}
+	return doc.WriteToBytes()
+}
+
+// =============================================================================
+//  Sign PARes and package it (deflate & base64) for PaRes form field
+// =============================================================================
+func (r *PARes) Package(s *PAResSigner) (string, error) {
+	b, err := r.Sign(s)
+	if err != nil {
+		return "", err
+	}
+	return PackThreeDSMessage(b)
+}
+
+// =============================================================================
+//  Helper function to create PARes document, returns Message & PARes elements
+// =============================================================================
+func (r *PARes) document() (*etree.Document, *etree.Element, *etree.Element, error) {
+	if len(r.MessageID) == 0 || len(r.MessageID) > 128 {
+		return nil, nil, nil, fmt.Errorf("Invalid PARes message id length: %d", len(r.MessageID))
+	}
+	if r.ID == "" {
+		return nil, nil, nil, fmt.Errorf("PARes id is missing")
+	}
+	if err := r.Validate(CARD_BRAND_UNKNOWN); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := validateThreeDSExtensions(r.Extensions); err != nil {
+		return nil, nil, nil, err
+	}
+	doc, msg := newThreeDSecureDocument(r.MessageID)
+	pr := msg.CreateElement("PARes")
+	pr.CreateAttr("id", r.ID)
+	pr.CreateElement("version").SetText(r.Version)
+
+	merch := pr.CreateElement("Merchant")
+	merch.CreateElement("acqBIN").SetText(r.Merchant.AcqBIN)
+	merch.CreateElement("merID").SetText(r.Merchant.MerID)
+
+	pu := pr.CreateElement("Purchase")
+	pu.CreateElement("xid").SetText(r.Purchase.XID)
+	pu.CreateElement("date").SetText(r.Purchase.Date.UTC().Format(TDS_DATE_FORMAT))
+	pu.CreateElement("purchAmount").SetText(strconv.FormatUint(r.Purchase.PurchAmount, 10))
+	pu.CreateElement("currency").SetText(r.Purchase.Currency)
+	pu.CreateElement("exponent").SetText(strconv.Itoa(int(r.Purchase.Exponent)))
+
+	pr.CreateElement("pan").SetText(r.PAN)
+
+	tx := pr.CreateElement("TX")
+	tx.CreateElement("time").SetText(r.TX.Time.UTC().Format(TDS_DATE_FORMAT))
+	tx.CreateElement("status").SetText(r.TX.Status)
+	if len(r.TX.CAVV) > 0 {
+		tx.CreateElement("cavv").SetText(base64.StdEncoding.EncodeToString(r.TX.CAVV))
+		tx.CreateElement("eci").SetText(r.TX.ECI)
+		tx.CreateElement("cavvAlgorithm").SetText(strconv.Itoa(int(r.TX.CAVVAlgorithm)))
+	}
+
+	if err := marshalThreeDSExtensions(pr, r.Extensions); err != nil {
+		return nil, nil, nil, err
+	}
+	return doc, msg, pr, nil
+}
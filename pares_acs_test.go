@@ -0,0 +1,95 @@
+package gocavv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Test VISA PARes generation, signing & packaging
+// =============================================================================
+func TestPAResBuildVisa(t *testing.T) {
+	root, rootKey := testCertificate(t, "Test Scheme Root CA", true, nil, nil)
+	acs, acsKey := testCertificate(t, "Test ACS", false, root, rootKey)
+
+	req, _ := UnpackPAReq(TEST_PAREQ_B64)
+	d := &ACSDecision{
+		Status:       TDS_TX_STATUS_A,
+		Time:         time.Date(2018, 6, 29, 17, 36, 1, 0, time.UTC),
+		ATN:          TEST_V_I_ATN,
+		SecondFactor: TEST_V_I_SECOND_ACODE,
+		CAVVKeyID:    TEST_V_I_CAVV_KEY_ID,
+	}
+	r, err := NewPARes(req, TEST_V_PAN_16, CARD_BRAND_VISA, d, NewSoftwareCryptoProvider(keyAV, keyBV), nil)
+	if err != nil {
+		t.Fatalf("[ACS]: Failed to build PARes: %s\n", err)
+	}
+	if hex.EncodeToString(r.TX.CAVV) != TEST_V_RS_CAVV || r.TX.ECI != "06" || r.TX.CAVVAlgorithm != TDS_CAVV_ALG_CVV_ATN {
+		t.Fatalf("[ACS]: Invalid PARes TX: %+v\n", r.TX)
+	}
+	if r.PAN != "0000000000002345" || r.Purchase.XID != req.Purchase.XID || r.MessageID != req.MessageID {
+		t.Fatalf("[ACS]: Invalid PARes: %+v\n", r)
+	}
+
+	b64, err := r.Package(&PAResSigner{Key: acsKey, Chain: []*x509.Certificate{acs}, Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("[ACS]: Failed to package PARes: %s\n", err)
+	}
+	v, err := PaResUnMarshalVerifiedMessage(b64, CARD_BRAND_VISA, NewTrustStore(root))
+	if err != nil {
+		t.Fatalf("[ACS]: Failed to verify generated PARes: %s\n", err)
+	}
+	if !bytes.Equal(v.TX.CAVV, r.TX.CAVV) || !v.TX.Time.Equal(d.Time) || !v.Purchase.Date.Equal(req.Purchase.Date) {
+		t.Fatalf("[ACS]: Invalid PARes after round trip: %+v\n", v)
+	}
+	if ok, err := VerifyVisaCavv(TEST_V_PAN_16, v.TX.CAVV, keyAV, keyBV); err != nil || !ok {
+		t.Fatalf("[ACS]: Failed to verify PARes CAVV: %v\n", err)
+	}
+}
+
+// =============================================================================
+// Test Master Card PARes generation
+// =============================================================================
+func TestPAResBuildMasterCard(t *testing.T) {
+	key, _ := hex.DecodeString("0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B")
+	req, _ := UnpackPAReq(TEST_PAREQ_B64)
+	req.Merchant.Name = TEST_MC_MERCH_NAME
+
+	d := &ACSDecision{
+		Status:     TDS_TX_STATUS_Y,
+		ACSID:      TEST_MC_ACS_ID,
+		AuthMethod: TEST_MC_ACS_AUTH_METHOD,
+		BINKeyID:   TEST_MC_BIN_KEY_ID,
+		TSN:        TEST_MC_TSN,
+	}
+	r, err := NewPARes(req, "5432109876543210", CARD_BRAND_MASTERCARD, d, nil, NewSoftwareMacProvider(key))
+	if err != nil {
+		t.Fatalf("[ACS]: Failed to build PARes: %s\n", err)
+	}
+	if !strings.EqualFold(hex.EncodeToString(r.TX.CAVV), "8C7CA7FBB6058B511401110000002F3547BA1EFF") ||
+		r.TX.ECI != "02" || r.TX.CAVVAlgorithm != TDS_CAVV_ALG_MC_SPA {
+		t.Fatalf("[ACS]: Invalid PARes TX: %+v\n", r.TX)
+	}
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("[ACS]: Failed to marshal PARes: %s\n", err)
+	}
+	if _, err := UnmarshalPARes(data, CARD_BRAND_MASTERCARD); err != nil {
+		t.Fatalf("[ACS]: Failed to unmarshal generated PARes: %s\n", err)
+	}
+
+	// Not authenticated: no AAV
+	d.Status = TDS_TX_STATUS_N
+	if r, err = NewPARes(req, "5432109876543210", CARD_BRAND_MASTERCARD, d, nil, nil); err != nil || r.TX.CAVV != nil {
+		t.Fatalf("[ACS]: Invalid not authenticated PARes: %v\n", err)
+	}
+	// Signing key is required
+	if _, err := r.Package(nil); err == nil {
+		t.Fatalf("[ACS]: Packaged PARes without signing key\n")
+	}
+}
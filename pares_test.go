@@ -0,0 +1,96 @@
+package gocavv
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// =============================================================================
+//  Helper function to build packaged test PARes
+// =============================================================================
+func testPAResB64(t *testing.T, status, cavv, eci, alg string) string {
+	var tx string
+
+	if cavv != "" {
+		b, _ := hex.DecodeString(cavv)
+		tx = fmt.Sprintf("<cavv>%s</cavv><eci>%s</eci><cavvAlgorithm>%s</cavvAlgorithm>", base64.StdEncoding.EncodeToString(b), eci, alg)
+	}
+	xml := `<?xml version="1.0" encoding="UTF-8"?><ThreeDSecure><Message id="PAReq:WzZ7wOjUsC96bI4eheuJ-A"><PARes id="PARes1">` +
+		`<version>1.0.2</version><Merchant><acqBIN>2201380114</acqBIN><merID>07070707</merID></Merchant>` +
+		`<Purchase><xid>1Twm0uVzoTmt3RPhhp1YrzhzEZM=</xid><date>20180629 17:35:12</date><purchAmount>100</purchAmount>` +
+		`<currency>840</currency><exponent>2</exponent></Purchase><pan>0000000000002945</pan>` +
+		`<TX><time>20180629 17:36:01</time><status>` + status + `</status>` + tx + `</TX></PARes></Message></ThreeDSecure>`
+	b64, err := PackThreeDSMessage([]byte(xml))
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to package PARes: %s\n", err)
+	}
+	return b64
+}
+
+// =============================================================================
+// Test VISA PARes unmarshalling
+// =============================================================================
+func TestPAResVisa(t *testing.T) {
+	r, err := PaResUnMarshalMessage(testPAResB64(t, "A", TEST_V_RS_CAVV, "06", "2"), CARD_BRAND_VISA)
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to unmarshal PARes: %s\n", err)
+	}
+	if r.ID != "PARes1" || r.Purchase.XID != "1Twm0uVzoTmt3RPhhp1YrzhzEZM=" || r.Merchant.MerID != "07070707" || r.Purchase.PurchAmount != 100 {
+		t.Fatalf("[3DS]: Invalid PARes echo fields: %+v\n", r)
+	}
+	if r.TX.Status != TDS_TX_STATUS_A || hex.EncodeToString(r.TX.CAVV) != TEST_V_RS_CAVV || r.TX.CAVVAlgorithm != TDS_CAVV_ALG_CVV_ATN {
+		t.Fatalf("[3DS]: Invalid PARes TX: %+v\n", r.TX)
+	}
+	ok, err := VerifyVisaCavv(TEST_V_PAN_16, r.TX.CAVV, keyAV, keyBV)
+	if err != nil || !ok {
+		t.Fatalf("[3DS]: Failed to verify PARes CAVV: %v\n", err)
+	}
+
+	// ECI for authenticated transaction with attempt CAVV
+	if _, err := PaResUnMarshalMessage(testPAResB64(t, "A", TEST_V_RS_CAVV, "05", "2"), CARD_BRAND_VISA); err == nil {
+		t.Fatalf("[3DS]: Unmarshalled PARes with invalid ECI\n")
+	}
+	// Authentication Results Code does not match status
+	if _, err := PaResUnMarshalMessage(testPAResB64(t, "Y", TEST_V_RS_CAVV, "05", "2"), CARD_BRAND_VISA); err == nil {
+		t.Fatalf("[3DS]: Unmarshalled PARes with invalid CAVV results code\n")
+	}
+	// Master Card algorithm for VISA card
+	if _, err := PaResUnMarshalMessage(testPAResB64(t, "A", TEST_V_RS_CAVV, "06", "3"), CARD_BRAND_VISA); err == nil {
+		t.Fatalf("[3DS]: Unmarshalled PARes with invalid CAVV algorithm\n")
+	}
+}
+
+// =============================================================================
+// Test Master Card PARes unmarshalling
+// =============================================================================
+func TestPAResMasterCard(t *testing.T) {
+	aav := "8C7CA7FBB6058B511401110000002F3547BA1EFF"
+	r, err := PaResUnMarshalMessage(testPAResB64(t, "Y", aav, "02", "3"), CARD_BRAND_MASTERCARD)
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to unmarshal PARes: %s\n", err)
+	}
+	if r.TX.ECI != "02" || r.TX.CAVV[0] != MC_AAV_CB_AUTHENTICATED {
+		t.Fatalf("[3DS]: Invalid PARes TX: %+v\n", r.TX)
+	}
+	if _, err := PaResUnMarshalMessage(testPAResB64(t, "A", aav, "01", "3"), CARD_BRAND_MASTERCARD); err == nil {
+		t.Fatalf("[3DS]: Unmarshalled PARes with invalid AAV control byte\n")
+	}
+}
+
+// =============================================================================
+// Test PARes without authentication value
+// =============================================================================
+func TestPAResNotAuthenticated(t *testing.T) {
+	r, err := PaResUnMarshalMessage(testPAResB64(t, "N", "", "", ""), CARD_BRAND_VISA)
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to unmarshal PARes: %s\n", err)
+	}
+	if r.TX.Status != TDS_TX_STATUS_N || r.TX.CAVV != nil {
+		t.Fatalf("[3DS]: Invalid PARes TX: %+v\n", r.TX)
+	}
+	if _, err := PaResUnMarshalMessage(testPAResB64(t, "Y", "", "", ""), CARD_BRAND_UNKNOWN); err == nil {
+		t.Fatalf("[3DS]: Unmarshalled authenticated PARes without CAVV\n")
+	}
+}
@@ -0,0 +1,253 @@
+//go:build pkcs11
+// +build pkcs11
+
+package gocavv
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// =============================================================================
+//  PKCS#11 provider configuration
+//
+//  Module    - path to PKCS#11 library (e.g. /usr/lib/softhsm/libsofthsm2.so)
+//  Slot      - token slot identifier
+//  PIN       - user PIN
+//  CVKALabel - label of CVK A (CKK_DES3 / CKK_DES2) for CVV2 & CAVV
+//  CVKBLabel - label of CVK B (CKK_DES3 / CKK_DES2) for CVV2 & CAVV
+//  HMACLabel - label of secret key for Master Card AAV & IAV HMAC
+//
+//  Key labels are optional, operations with unconfigured key fail.
+// =============================================================================
+type PKCS11Config struct {
+	Module    string
+	Slot      uint
+	PIN       string
+	CVKALabel string
+	CVKBLabel string
+	HMACLabel string
+}
+
+// =============================================================================
+//  PKCS11Provider implements CryptoProvider & MacProvider with key handles
+//  kept in PKCS#11 token. Single session is used, operations are serialized.
+// =============================================================================
+type PKCS11Provider struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	keyA    pkcs11.ObjectHandle
+	keyB    pkcs11.ObjectHandle
+	hmacKey pkcs11.ObjectHandle
+	hasCVK  bool
+	hasHMAC bool
+	mu      sync.Mutex
+}
+
+// =============================================================================
+//  Create PKCS#11 provider: load module, open session, login & find keys
+// =============================================================================
+func NewPKCS11Provider(cfg PKCS11Config) (*PKCS11Provider, error) {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("Failed to load PKCS#11 module: %s", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+	p := &PKCS11Provider{ctx: ctx}
+
+	session, err := ctx.OpenSession(cfg.Slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		p.finalize()
+		return nil, err
+	}
+	p.session = session
+
+	if err := ctx.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil {
+		if e, ok := err.(pkcs11.Error); !ok || e != pkcs11.CKR_USER_ALREADY_LOGGED_IN {
+			p.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.CVKALabel != "" || cfg.CVKBLabel != "" {
+		if p.keyA, err = p.findKey(cfg.CVKALabel); err != nil {
+			p.Close()
+			return nil, err
+		}
+		if p.keyB, err = p.findKey(cfg.CVKBLabel); err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.hasCVK = true
+	}
+	if cfg.HMACLabel != "" {
+		if p.hmacKey, err = p.findKey(cfg.HMACLabel); err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.hasHMAC = true
+	}
+	return p, nil
+}
+
+// =============================================================================
+//  Close session & unload PKCS#11 module
+// =============================================================================
+func (p *PKCS11Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return nil
+	}
+	p.ctx.Logout(p.session)
+	err := p.ctx.CloseSession(p.session)
+	p.finalize()
+	return err
+}
+
+// =============================================================================
+//  Helper function to finalize & unload module
+// =============================================================================
+func (p *PKCS11Provider) finalize() {
+	p.ctx.Finalize()
+	p.ctx.Destroy()
+	p.ctx = nil
+}
+
+// =============================================================================
+//  Helper function to find secret key handle by label
+// =============================================================================
+func (p *PKCS11Provider) findKey(label string) (pkcs11.ObjectHandle, error) {
+	tmpl := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := p.ctx.FindObjectsInit(p.session, tmpl); err != nil {
+		return 0, err
+	}
+	objs, _, err := p.ctx.FindObjects(p.session, 2)
+	p.ctx.FindObjectsFinal(p.session)
+	if err != nil {
+		return 0, err
+	}
+	if len(objs) != 1 {
+		return 0, fmt.Errorf("Failed to find PKCS#11 secret key with label %q: %d objects found", label, len(objs))
+	}
+	return objs[0], nil
+}
+
+func (p *PKCS11Provider) GenerateCVV2(pan, atn, scode string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return 0, fmt.Errorf("PKCS#11 provider is closed")
+	}
+	if !p.hasCVK {
+		return 0, fmt.Errorf("PKCS#11 CVK A/B labels are not configured")
+	}
+	cipherA := &pkcs11Block{p: p, key: p.keyA}
+	cipherB := &pkcs11Block{p: p, key: p.keyB}
+
+	cvv2, err := generateCVV2Block(pan, atn, scode, cipherA, cipherB)
+	if err == nil {
+		err = cipherA.err
+	}
+	if err == nil {
+		err = cipherB.err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return cvv2, nil
+}
+
+func (p *PKCS11Provider) VerifyCVV2(pan, atn, scode string, cvv2 int) (bool, error) {
+	c, err := p.GenerateCVV2(pan, atn, scode)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeEq(int32(c), int32(cvv2)) == 1, nil
+}
+
+func (p *PKCS11Provider) HMAC(h crypto.Hash, data []byte) ([]byte, error) {
+	var mech uint
+
+	switch h {
+	case crypto.SHA1:
+		mech = pkcs11.CKM_SHA_1_HMAC
+	case crypto.SHA256:
+		mech = pkcs11.CKM_SHA256_HMAC
+	default:
+		return nil, fmt.Errorf("Unsupported HMAC hash function: %d", h)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return nil, fmt.Errorf("PKCS#11 provider is closed")
+	}
+	if !p.hasHMAC {
+		return nil, fmt.Errorf("PKCS#11 HMAC key label is not configured")
+	}
+	if err := p.ctx.SignInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mech, nil)}, p.hmacKey); err != nil {
+		return nil, err
+	}
+	return p.ctx.Sign(p.session, data)
+}
+
+// =============================================================================
+//  pkcs11Block implements cipher.Block with DES3 ECB key handle, the first
+//  error is kept since cipher.Block can not return it
+// =============================================================================
+type pkcs11Block struct {
+	p   *PKCS11Provider
+	key pkcs11.ObjectHandle
+	err error
+}
+
+func (b *pkcs11Block) BlockSize() int { return 8 }
+
+func (b *pkcs11Block) Encrypt(dst, src []byte) {
+	b.crypt(dst, src, false)
+}
+
+func (b *pkcs11Block) Decrypt(dst, src []byte) {
+	b.crypt(dst, src, true)
+}
+
+func (b *pkcs11Block) crypt(dst, src []byte, decrypt bool) {
+	var out []byte
+	var err error
+
+	if b.err != nil {
+		return
+	}
+	mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_DES3_ECB, nil)}
+	if decrypt {
+		if err = b.p.ctx.DecryptInit(b.p.session, mech, b.key); err == nil {
+			out, err = b.p.ctx.Decrypt(b.p.session, src[:8])
+		}
+	} else {
+		if err = b.p.ctx.EncryptInit(b.p.session, mech, b.key); err == nil {
+			out, err = b.p.ctx.Encrypt(b.p.session, src[:8])
+		}
+	}
+	if err == nil && len(out) != 8 {
+		err = fmt.Errorf("Invalid PKCS#11 DES3 ECB output length: %d", len(out))
+	}
+	if err != nil {
+		b.err = err
+		return
+	}
+	copy(dst, out)
+}
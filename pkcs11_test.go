@@ -0,0 +1,156 @@
+//go:build pkcs11
+// +build pkcs11
+
+package gocavv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+)
+
+/*
+Tests run against local SoftHSM token:
+
+	softhsm2-util --init-token --free --label gocavv --pin 1234 --so-pin 1234
+	PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so PKCS11_SLOT=<slot> PKCS11_PIN=1234 go test -tags pkcs11
+*/
+
+// =============================================================================
+//  Helper function to read token configuration from environment
+// =============================================================================
+func pkcs11TestConfig(t *testing.T) PKCS11Config {
+	module := os.Getenv("PKCS11_MODULE")
+	if module == "" {
+		t.Skip("[PKCS11]: PKCS11_MODULE is not set")
+	}
+	slot, _ := strconv.ParseUint(os.Getenv("PKCS11_SLOT"), 10, 32)
+	return PKCS11Config{Module: module, Slot: uint(slot), PIN: os.Getenv("PKCS11_PIN")}
+}
+
+// =============================================================================
+//  Helper function to import test keys as token objects, returns cleanup
+// =============================================================================
+func pkcs11ImportTestKeys(t *testing.T, cfg *PKCS11Config, keyA, keyB, hkey []byte) func() {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		t.Fatalf("[PKCS11]: Failed to load module: %s\n", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		t.Fatalf("[PKCS11]: Failed to initialize module: %s\n", err)
+	}
+	session, err := ctx.OpenSession(cfg.Slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatalf("[PKCS11]: Failed to open session: %s\n", err)
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil {
+		t.Fatalf("[PKCS11]: Failed to login: %s\n", err)
+	}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	cfg.CVKALabel, cfg.CVKBLabel, cfg.HMACLabel = "cvka-"+suffix, "cvkb-"+suffix, "hmac-"+suffix
+
+	var objs []pkcs11.ObjectHandle
+	create := func(label string, keyType uint, value []byte) {
+		o, err := ctx.CreateObject(session, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, keyType == pkcs11.CKK_DES3),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, keyType == pkcs11.CKK_DES3),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, keyType == pkcs11.CKK_GENERIC_SECRET),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, value),
+		})
+		if err != nil {
+			t.Fatalf("[PKCS11]: Failed to import key %s: %s\n", label, err)
+		}
+		objs = append(objs, o)
+	}
+	create(cfg.CVKALabel, pkcs11.CKK_DES3, bytes.Repeat(keyA, 3))
+	create(cfg.CVKBLabel, pkcs11.CKK_DES3, bytes.Repeat(keyB, 3))
+	create(cfg.HMACLabel, pkcs11.CKK_GENERIC_SECRET, hkey)
+
+	ctx.Logout(session)
+	ctx.CloseSession(session)
+	ctx.Finalize()
+
+	return func() {
+		ctx.Initialize()
+		session, _ := ctx.OpenSession(cfg.Slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		ctx.Login(session, pkcs11.CKU_USER, cfg.PIN)
+		for _, o := range objs {
+			ctx.DestroyObject(session, o)
+		}
+		ctx.Logout(session)
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+	}
+}
+
+// =============================================================================
+//  Test VISA CAVV & Master Card AAV HMAC-SHA1 with PKCS#11 key handles
+// =============================================================================
+func TestPKCS11_Provider(t *testing.T) {
+	cfg := pkcs11TestConfig(t)
+	hkey, _ := hex.DecodeString("0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B")
+	cleanup := pkcs11ImportTestKeys(t, &cfg, keyAV, keyBV, hkey)
+	defer cleanup()
+
+	p, err := NewPKCS11Provider(cfg)
+	if err != nil {
+		t.Fatalf("[PKCS11]: Failed to create provider: %s\n", err)
+	}
+	defer p.Close()
+
+	cavv, err := GenerateVisaCavvWithProvider(TEST_V_PAN_16, TEST_V_I_ATN, TEST_V_I_AUTH_RC, TEST_V_I_SECOND_ACODE, TEST_V_I_CAVV_KEY_ID, p)
+	if err != nil {
+		t.Fatalf("[PKCS11]: Failed to generate CAVV: %s\n", err)
+	}
+	if scavv := hex.EncodeToString(cavv); scavv != TEST_V_RS_CAVV {
+		t.Fatalf("[PKCS11]: Invalid CAVV: %s\n\texpected: %s\n", scavv, TEST_V_RS_CAVV)
+	}
+
+	aav := "8C7CA7FBB6058B511401110000002F3547BA1EFF"
+	b, err := GenerateMasterCardAAVWithProvider(MC_HMAC_SHA1, "5432109876543210", TEST_MC_CONTOL_BYTE, TEST_MC_MERCH_NAME,
+		TEST_MC_ACS_ID, TEST_MC_ACS_AUTH_METHOD, TEST_MC_BIN_KEY_ID, TEST_MC_TSN, nil, nil, p, p)
+	if err != nil {
+		t.Fatalf("[PKCS11]: Failed to generate MasterCard AAV: %s\n", err)
+	}
+	if bs := hex.EncodeToString(b); !strings.EqualFold(bs, aav) {
+		t.Fatalf("[PKCS11]: Invalid AAV: %s\n\texpected: %s\n", bs, aav)
+	}
+}
+
+// =============================================================================
+//  Test Master Card IAV HMAC-SHA256 with PKCS#11 key handle
+// =============================================================================
+func TestPKCS11_IAV(t *testing.T) {
+	cfg := pkcs11TestConfig(t)
+	hkey, _ := hex.DecodeString("B039878C1F96D212F509B2DC4CC8CD1B")
+	cleanup := pkcs11ImportTestKeys(t, &cfg, keyAV, keyBV, hkey)
+	defer cleanup()
+
+	p, err := NewPKCS11Provider(cfg)
+	if err != nil {
+		t.Fatalf("[PKCS11]: Failed to create provider: %s\n", err)
+	}
+	defer p.Close()
+
+	iav := "C6041862065500000000000000000000000000000000000000000000"
+	b, err := GenerateMasterCardIAVWithProvider("2226400099919520", TEST_MC_MERCH_NAME_IAV, 123456, 840, 0x2C1C0497, p)
+	if err != nil {
+		t.Fatalf("[PKCS11]: Failed to generate MasterCard IAV: %s\n", err)
+	}
+	if bs := hex.EncodeToString(b); !strings.EqualFold(bs, iav) {
+		t.Fatalf("[PKCS11]: Invalid IAV: %s\n\texpected: %s\n", bs, iav)
+	}
+}
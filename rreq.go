@@ -0,0 +1,191 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+RReq message EMV 3-D Secure 2.x (ACS -> DS -> 3DS Server, final challenge outcome):
+------------------------------------------------------------------------------------------------------------------------
+|  Field                              |  Required                                              |   Format              |
+------------------------------------------------------------------------------------------------------------------------
+|  messageType, messageVersion        |  always                                                |   RReq, 2.x.x         |
+|  threeDSServerTransID               |  always, from AReq                                     |   UUID (36 chars)     |
+|  acsTransID, dsTransID              |  always, from ARes                                     |   UUID (36 chars)     |
+|  sdkTransID                         |  APP, from AReq                                        |   UUID (36 chars)     |
+|  messageCategory                    |  always, from AReq                                     |   01, 02              |
+|  transStatus                        |  always                                                |   Y N U A R           |
+|  interactionCounter                 |  challenge performed                                   |   2 digits            |
+|  authenticationType                 |  challenge performed                                   |   2 digits            |
+------------------------------------------------------------------------------------------------------------------------
+|  authenticationValue                |  transStatus Y, A (CAVV / AAV)                         |   28 chars (base64)   |
+|  eci                                |  transStatus Y, A (payment)                            |   2 digits            |
+|  transStatusReason                  |  transStatus N, U, R                                   |   2 digits            |
+|  challengeCancel                    |  challenge cancelled                                   |   2 digits            |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+// =============================================================================
+//  RReq (Results Request) EMV 3-D Secure 2.x
+// =============================================================================
+type RReq struct {
+	MessageType    string `json:"messageType"`
+	MessageVersion string `json:"messageVersion"`
+
+	ThreeDSServerTransID string `json:"threeDSServerTransID"`
+	ACSTransID           string `json:"acsTransID"`
+	DSTransID            string `json:"dsTransID"`
+	SDKTransID           string `json:"sdkTransID,omitempty"`
+	MessageCategory      string `json:"messageCategory"`
+
+	TransStatus         string               `json:"transStatus"`
+	TransStatusReason   string               `json:"transStatusReason,omitempty"`
+	AuthenticationType  string               `json:"authenticationType,omitempty"`
+	AuthenticationValue string               `json:"authenticationValue,omitempty"`
+	ECI                 string               `json:"eci,omitempty"`
+	ChallengeCancel     string               `json:"challengeCancel,omitempty"`
+	InteractionCounter  string               `json:"interactionCounter,omitempty"`
+	ACSRenderingType    *EMV3DSRenderingType `json:"acsRenderingType,omitempty"`
+
+	MessageExtension []EMV3DSMessageExtension `json:"messageExtension,omitempty"`
+}
+
+// =============================================================================
+//  ACS final authentication decision reported in RReq
+// =============================================================================
+type ACSResult struct {
+	TransStatus         string
+	TransStatusReason   string
+	AuthenticationType  string
+	AuthenticationValue []byte /* CAVV / AAV generated by ACS (Y, A) */
+	ECI                 string
+	ChallengeCancel     string
+	InteractionCounter  uint8
+}
+
+// =============================================================================
+//  Create RReq for authentication (AReq & challenge ARes) from ACS result
+// =============================================================================
+func NewRReq(areq *AReq, ares *ARes, res *ACSResult) (*RReq, error) {
+	if ares.TransStatus != EMV3DS_TRANS_STATUS_C && ares.TransStatus != EMV3DS_TRANS_STATUS_D {
+		return nil, fmt.Errorf("RReq is not sent for ARes with transStatus %s", ares.TransStatus)
+	}
+	r := &RReq{
+		MessageType:          "RReq",
+		MessageVersion:       areq.MessageVersion,
+		ThreeDSServerTransID: areq.ThreeDSServerTransID,
+		ACSTransID:           ares.ACSTransID,
+		DSTransID:            ares.DSTransID,
+		SDKTransID:           areq.SDKTransID,
+		MessageCategory:      areq.MessageCategory,
+		TransStatus:          res.TransStatus,
+		TransStatusReason:    res.TransStatusReason,
+		AuthenticationType:   res.AuthenticationType,
+		ECI:                  res.ECI,
+		ChallengeCancel:      res.ChallengeCancel,
+		InteractionCounter:   fmt.Sprintf("%02d", res.InteractionCounter),
+		ACSRenderingType:     ares.ACSRenderingType,
+	}
+	if r.AuthenticationType == "" {
+		r.AuthenticationType = ares.AuthenticationType
+	}
+	if len(res.AuthenticationValue) > 0 {
+		var err error
+		if r.AuthenticationValue, err = encodeEMV3DSAuthValue(res.AuthenticationValue); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.Validate(areq); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  Validate RReq fields, with AReq (may be nil) the transaction ids must match
+// =============================================================================
+func (r *RReq) Validate(areq *AReq) error {
+	if err := checkEMV3DSHeader(r.MessageType, "RReq", r.MessageVersion); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("threeDSServerTransID", r.ThreeDSServerTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("acsTransID", r.ACSTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("dsTransID", r.DSTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSEnum("messageCategory", r.MessageCategory, EMV3DS_CATEGORY_PA, EMV3DS_CATEGORY_NPA); err != nil {
+		return err
+	}
+	if err := checkEMV3DSEnum("transStatus", r.TransStatus,
+		EMV3DS_TRANS_STATUS_Y, EMV3DS_TRANS_STATUS_N, EMV3DS_TRANS_STATUS_U, EMV3DS_TRANS_STATUS_A, EMV3DS_TRANS_STATUS_R); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("interactionCounter", r.InteractionCounter, 2, 2); err != nil {
+		return err
+	}
+	if err := checkEMV3DSDigits("authenticationType", r.AuthenticationType, 2, 2); err != nil {
+		return err
+	}
+	if r.ChallengeCancel != "" {
+		if err := checkEMV3DSEnum("challengeCancel", r.ChallengeCancel, "01", "04", "05", "06", "07", "08"); err != nil {
+			return err
+		}
+	}
+	if areq != nil {
+		if r.ThreeDSServerTransID != areq.ThreeDSServerTransID || r.MessageCategory != areq.MessageCategory {
+			return fmt.Errorf("RReq does not match AReq %s", areq.ThreeDSServerTransID)
+		}
+		if areq.DeviceChannel == EMV3DS_CHANNEL_APP && r.SDKTransID != areq.SDKTransID {
+			return fmt.Errorf("RReq sdkTransID %q does not match AReq: %q", r.SDKTransID, areq.SDKTransID)
+		}
+	}
+
+	switch r.TransStatus {
+	case EMV3DS_TRANS_STATUS_Y, EMV3DS_TRANS_STATUS_A:
+		if _, err := decodeEMV3DSAuthValue(r.AuthenticationValue); err != nil {
+			return err
+		}
+		if r.MessageCategory == EMV3DS_CATEGORY_PA {
+			if err := checkEMV3DSDigits("eci", r.ECI, 2, 2); err != nil {
+				return err
+			}
+		}
+	default:
+		if r.AuthenticationValue != "" {
+			return fmt.Errorf("EMV 3-D Secure RReq with transStatus %s must not contain authenticationValue", r.TransStatus)
+		}
+		if err := checkEMV3DSDigits("transStatusReason", r.TransStatusReason, 2, 2); err != nil {
+			return err
+		}
+	}
+	return validateEMV3DSExtensions(r.MessageExtension)
+}
+
+// =============================================================================
+//  Validate & marshal RReq to JSON
+// =============================================================================
+func (r *RReq) Marshal() ([]byte, error) {
+	if err := r.Validate(nil); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// =============================================================================
+//  Parse & validate RReq JSON message for AReq (may be nil)
+// =============================================================================
+func UnmarshalRReq(data []byte, areq *AReq) (*RReq, error) {
+	r := &RReq{}
+	if err := unmarshalEMV3DSMessage(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(areq); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,84 @@
+package gocavv
+
+import (
+	"testing"
+)
+
+// =============================================================================
+// Test RReq generation for completed challenge & RRes acknowledgement
+// =============================================================================
+func TestRReqResults(t *testing.T) {
+	areq := testAReqApp(EMV3DS_VER_220)
+	ares := testARes(areq, EMV3DS_TRANS_STATUS_C)
+	ares.ACSChallengeMandated, ares.AuthenticationType = "Y", "02"
+
+	creq := testCReqApp()
+	c := &ChallengeCompletion{AReq: areq, CRes: testCRes(creq, "Y", EMV3DS_TRANS_STATUS_Y), UIType: EMV3DS_UI_TEXT}
+	cavv, err := c.GenerateVisaCavv(TEST_V_I_ATN, TEST_V_I_CAVV_KEY_ID, NewSoftwareCryptoProvider(keyAV, keyBV))
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to generate CAVV: %s\n", err)
+	}
+
+	if _, err := NewRReq(areq, testARes(areq, EMV3DS_TRANS_STATUS_Y), &ACSResult{TransStatus: EMV3DS_TRANS_STATUS_Y}); err == nil {
+		t.Fatalf("[EMV3DS]: Created RReq for frictionless ARes\n")
+	}
+	if _, err := NewRReq(areq, ares, &ACSResult{TransStatus: EMV3DS_TRANS_STATUS_Y, InteractionCounter: 1}); err == nil {
+		t.Fatalf("[EMV3DS]: Created RReq with status Y without authentication value\n")
+	}
+	rreq, err := NewRReq(areq, ares, &ACSResult{
+		TransStatus:         EMV3DS_TRANS_STATUS_Y,
+		AuthenticationValue: cavv,
+		ECI:                 "05",
+		InteractionCounter:  1,
+	})
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to create RReq: %s\n", err)
+	}
+	data, err := rreq.Marshal()
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to marshal RReq: %s\n", err)
+	}
+	u, err := UnmarshalRReq(data, areq)
+	if err != nil || u.AuthenticationType != "02" || u.InteractionCounter != "01" || u.DSTransID != TEST_3DS_DS_TRANS_ID {
+		t.Fatalf("[EMV3DS]: Failed to unmarshal RReq: %v\n", err)
+	}
+	other := testAReqApp(EMV3DS_VER_220)
+	other.SDKTransID = TEST_3DS_ACS_TRANS_ID
+	if _, err := UnmarshalRReq(data, other); err == nil {
+		t.Fatalf("[EMV3DS]: Unmarshalled RReq for another AReq\n")
+	}
+
+	// 3DS Server acknowledgement
+	rres, err := NewRRes(u, EMV3DS_RESULTS_RECEIVED)
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to create RRes: %s\n", err)
+	}
+	data, err = rres.Marshal(u)
+	if err != nil {
+		t.Fatalf("[EMV3DS]: Failed to marshal RRes: %s\n", err)
+	}
+	if r, err := UnmarshalRRes(data, rreq); err != nil || !r.IsReceived() {
+		t.Fatalf("[EMV3DS]: Failed to unmarshal RRes: %v\n", err)
+	}
+	rres.ACSTransID = TEST_3DS_SDK_TRANS_ID
+	if err := rres.Validate(rreq); err == nil {
+		t.Fatalf("[EMV3DS]: Validated RRes with mismatched acsTransID\n")
+	}
+	if _, err := NewRRes(rreq, "04"); err == nil {
+		t.Fatalf("[EMV3DS]: Created RRes with invalid resultsStatus\n")
+	}
+
+	// Failed challenge: transStatusReason required, no authentication value
+	res := &ACSResult{TransStatus: EMV3DS_TRANS_STATUS_N, InteractionCounter: 3}
+	if _, err := NewRReq(areq, ares, res); err == nil {
+		t.Fatalf("[EMV3DS]: Created RReq with status N without transStatusReason\n")
+	}
+	res.TransStatusReason, res.AuthenticationValue = "19", cavv
+	if _, err := NewRReq(areq, ares, res); err == nil {
+		t.Fatalf("[EMV3DS]: Created RReq with status N with authentication value\n")
+	}
+	res.AuthenticationValue = nil
+	if _, err := NewRReq(areq, ares, res); err != nil {
+		t.Fatalf("[EMV3DS]: Failed to create RReq with status N: %s\n", err)
+	}
+}
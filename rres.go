@@ -0,0 +1,115 @@
+package gocavv
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// RRes results message status
+	EMV3DS_RESULTS_RECEIVED           string = "01" /* Results request received for further processing */
+	EMV3DS_RESULTS_CHALLENGE_ABORTED  string = "02" /* Challenge request not sent to ACS by 3DS Requestor */
+	EMV3DS_RESULTS_CHALLENGE_NOT_SENT string = "03" /* ACS challenge data not delivered (2.2.0+)         */
+)
+
+// =============================================================================
+//  RRes (Results Response) EMV 3-D Secure 2.x, acknowledgement of RReq
+// =============================================================================
+type RRes struct {
+	MessageType    string `json:"messageType"`
+	MessageVersion string `json:"messageVersion"`
+
+	ThreeDSServerTransID string `json:"threeDSServerTransID"`
+	ACSTransID           string `json:"acsTransID"`
+	DSTransID            string `json:"dsTransID"`
+	SDKTransID           string `json:"sdkTransID,omitempty"`
+	ResultsStatus        string `json:"resultsStatus"`
+
+	MessageExtension []EMV3DSMessageExtension `json:"messageExtension,omitempty"`
+}
+
+// =============================================================================
+//  Create RRes acknowledging RReq (3DS Server side)
+// =============================================================================
+func NewRRes(req *RReq, status string) (*RRes, error) {
+	r := &RRes{
+		MessageType:          "RRes",
+		MessageVersion:       req.MessageVersion,
+		ThreeDSServerTransID: req.ThreeDSServerTransID,
+		ACSTransID:           req.ACSTransID,
+		DSTransID:            req.DSTransID,
+		SDKTransID:           req.SDKTransID,
+		ResultsStatus:        status,
+	}
+	if err := r.Validate(req); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  Validate RRes fields, with RReq (may be nil) the transaction ids must be
+//  echoed
+// =============================================================================
+func (r *RRes) Validate(req *RReq) error {
+	if err := checkEMV3DSHeader(r.MessageType, "RRes", r.MessageVersion); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("threeDSServerTransID", r.ThreeDSServerTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("acsTransID", r.ACSTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSTransID("dsTransID", r.DSTransID); err != nil {
+		return err
+	}
+	if err := checkEMV3DSEnum("resultsStatus", r.ResultsStatus,
+		EMV3DS_RESULTS_RECEIVED, EMV3DS_RESULTS_CHALLENGE_ABORTED, EMV3DS_RESULTS_CHALLENGE_NOT_SENT); err != nil {
+		return err
+	}
+	if r.MessageVersion == EMV3DS_VER_210 && r.ResultsStatus == EMV3DS_RESULTS_CHALLENGE_NOT_SENT {
+		return fmt.Errorf("Invalid EMV 3-D Secure field resultsStatus %s for version %s", r.ResultsStatus, r.MessageVersion)
+	}
+	if req != nil {
+		if r.MessageVersion != req.MessageVersion {
+			return fmt.Errorf("RRes messageVersion %q does not match RReq: %q", r.MessageVersion, req.MessageVersion)
+		}
+		if r.ThreeDSServerTransID != req.ThreeDSServerTransID || r.ACSTransID != req.ACSTransID ||
+			r.DSTransID != req.DSTransID || r.SDKTransID != req.SDKTransID {
+			return fmt.Errorf("RRes transaction ids do not match RReq")
+		}
+	}
+	return validateEMV3DSExtensions(r.MessageExtension)
+}
+
+// =============================================================================
+//  Check RReq results were received by 3DS Server
+// =============================================================================
+func (r *RRes) IsReceived() bool {
+	return r.ResultsStatus == EMV3DS_RESULTS_RECEIVED
+}
+
+// =============================================================================
+//  Validate & marshal RRes to JSON
+// =============================================================================
+func (r *RRes) Marshal(req *RReq) ([]byte, error) {
+	if err := r.Validate(req); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// =============================================================================
+//  Parse & validate RRes JSON message for RReq (may be nil)
+// =============================================================================
+func UnmarshalRRes(data []byte, req *RReq) (*RRes, error) {
+	r := &RRes{}
+	if err := unmarshalEMV3DSMessage(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(req); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
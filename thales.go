@@ -0,0 +1,275 @@
+package gocavv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+payShield host commands used for the CAVV / CVC2 calculation:
+---------------------------------------------------------------------------------------------------------------
+| Command | Response |                            Meaning                                                     |
+---------------------------------------------------------------------------------------------------------------
+|   CW    |    CX    | Generate a VISA CVV: CVK A/B, PAN, ';', expiry date (4N), service code (3N)            |
+---------------------------------------------------------------------------------------------------------------
+|   CY    |    CZ    | Verify a VISA CVV: CVK A/B, CVV (3N), PAN, ';', expiry date (4N), service code (3N)    |
+---------------------------------------------------------------------------------------------------------------
+
+The CAVV (and CVC2 based AAV) are calculated with the CVV algorithm, where the four least significant
+digits of the ATN are submitted in place of the expiry date and the service code is built from the
+Authentication Results Code & Second Factor Authentication Code.
+
+CVK A/B is encrypted under LMK pair 14-15 and is sent as 32H (two single length keys) or 'U' + 32H
+(double length key). Every message is prefixed with 2 bytes length (big endian) and a message header
+echoed back by the HSM.
+
+Error codes:
+---------------------------------------------------------------------------------------------------------------
+|   00    | No error                                                                                          |
+|   01    | CVV failed verification                                                                           |
+|   10    | CVK A or B parity error                                                                           |
+|   15    | Error in input data                                                                               |
+|   27    | CVK not double length                                                                             |
+|   68    | Command has been disabled                                                                         |
+---------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	THALES_DEFAULT_HEADER_LEN int           = 4
+	THALES_DEFAULT_TIMEOUT    time.Duration = 5 * time.Second
+
+	THALES_ERR_OK              string = "00"
+	THALES_ERR_VERIFY_FAILURE  string = "01"
+	THALES_ERR_KEY_PARITY      string = "10"
+	THALES_ERR_INPUT_DATA      string = "15"
+	THALES_ERR_KEY_LENGTH      string = "27"
+	THALES_ERR_CMD_DISABLED    string = "68"
+)
+
+// =============================================================================
+//  Error returned by payShield in response code
+// =============================================================================
+type ThalesError struct {
+	Command string
+	Code    string
+}
+
+func (e *ThalesError) Error() string {
+	return fmt.Sprintf("payShield command %s failed with error code: %s", e.Command, e.Code)
+}
+
+// =============================================================================
+//  payShield host command client (single TCP connection, requests serialized)
+// =============================================================================
+type ThalesClient struct {
+	Addr      string        /* HSM address host:port  */
+	HeaderLen int           /* Message header length  */
+	Timeout   time.Duration /* Dial & I/O timeout     */
+
+	mu   sync.Mutex
+	conn net.Conn
+	seq  uint64
+}
+
+// =============================================================================
+//  Create payShield host command client
+// =============================================================================
+func NewThalesClient(addr string) *ThalesClient {
+	return &ThalesClient{Addr: addr, HeaderLen: THALES_DEFAULT_HEADER_LEN, Timeout: THALES_DEFAULT_TIMEOUT}
+}
+
+// =============================================================================
+//  Close connection to HSM
+// =============================================================================
+func (c *ThalesClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// =============================================================================
+//  Send host command to HSM and return response data following error code
+// =============================================================================
+func (c *ThalesClient) Command(cmd string, data []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(cmd) != 2 {
+		return nil, fmt.Errorf("Invalid payShield command code: %q", cmd)
+	}
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.Addr, c.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	// Build message header
+	c.seq++
+	header := fmt.Sprintf("%0*d", c.HeaderLen, c.seq)
+	header = header[len(header)-c.HeaderLen:]
+
+	msg := make([]byte, 0, c.HeaderLen+2+len(data))
+	msg = append(msg, header...)
+	msg = append(msg, cmd...)
+	msg = append(msg, data...)
+
+	resp, err := c.roundTrip(msg)
+	if err == nil {
+		err = c.checkResponse(resp, header, cmd)
+	}
+	if err != nil {
+		// Drop connection out of sync with HSM (stale or partial frame),
+		// next command reconnects
+		c.conn.Close()
+		c.conn = nil
+		return nil, err
+	}
+	if ecode := string(resp[c.HeaderLen+2 : c.HeaderLen+4]); ecode != THALES_ERR_OK {
+		return nil, &ThalesError{Command: cmd, Code: ecode}
+	}
+	return resp[c.HeaderLen+4:], nil
+}
+
+// =============================================================================
+//  Helper function to check response framing: header & response code
+// =============================================================================
+func (c *ThalesClient) checkResponse(resp []byte, header, cmd string) error {
+	if len(resp) < c.HeaderLen+4 {
+		return fmt.Errorf("Invalid payShield response length: %d", len(resp))
+	}
+	if string(resp[:c.HeaderLen]) != header {
+		return fmt.Errorf("Invalid payShield response header: %q, expected: %q", resp[:c.HeaderLen], header)
+	}
+	if rcode := string(resp[c.HeaderLen : c.HeaderLen+2]); rcode != thalesResponseCode(cmd) {
+		return fmt.Errorf("Invalid payShield response code: %s, expected: %s", rcode, thalesResponseCode(cmd))
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to write length prefixed message and read response
+// =============================================================================
+func (c *ThalesClient) roundTrip(msg []byte) ([]byte, error) {
+	if c.Timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	if err := writeThalesFrame(c.conn, msg); err != nil {
+		return nil, err
+	}
+	return readThalesFrame(c.conn)
+}
+
+// =============================================================================
+//  Helper function to write 2 bytes length prefixed frame
+// =============================================================================
+func writeThalesFrame(w io.Writer, msg []byte) error {
+	if len(msg) > 0xFFFF {
+		return fmt.Errorf("Invalid payShield message length: %d", len(msg))
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// =============================================================================
+//  Helper function to read 2 bytes length prefixed frame
+// =============================================================================
+func readThalesFrame(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// =============================================================================
+//  Helper function to get response code: second character incremented
+// =============================================================================
+func thalesResponseCode(cmd string) string {
+	return string([]byte{cmd[0], cmd[1] + 1})
+}
+
+// =============================================================================
+//  ThalesCryptoProvider implements CryptoProvider with payShield CW/CY
+//  commands. CVK is the CVK A/B pair encrypted under LMK (32H or U + 32H).
+// =============================================================================
+type ThalesCryptoProvider struct {
+	Client *ThalesClient
+	CVK    string
+}
+
+// =============================================================================
+//  Create payShield crypto provider
+// =============================================================================
+func NewThalesCryptoProvider(client *ThalesClient, cvk string) *ThalesCryptoProvider {
+	return &ThalesCryptoProvider{Client: client, CVK: cvk}
+}
+
+func (p *ThalesCryptoProvider) GenerateCVV2(pan, atn, scode string) (int, error) {
+	if err := checkThalesCVVInput(pan, atn, scode); err != nil {
+		return 0, err
+	}
+	resp, err := p.Client.Command("CW", []byte(p.CVK+pan+";"+atn+scode))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) != 3 {
+		return 0, fmt.Errorf("Invalid payShield CVV length: %d, expected: 3", len(resp))
+	}
+	return strconv.Atoi(string(resp))
+}
+
+func (p *ThalesCryptoProvider) VerifyCVV2(pan, atn, scode string, cvv2 int) (bool, error) {
+	if err := checkThalesCVVInput(pan, atn, scode); err != nil {
+		return false, err
+	}
+	if cvv2 < 0 || cvv2 > 999 {
+		return false, fmt.Errorf("Invalid CVV2 value: %d", cvv2)
+	}
+	_, err := p.Client.Command("CY", []byte(fmt.Sprintf("%s%03d%s;%s%s", p.CVK, cvv2, pan, atn, scode)))
+	if err != nil {
+		if e, ok := err.(*ThalesError); ok && e.Code == THALES_ERR_VERIFY_FAILURE {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// =============================================================================
+//  Helper function to check CW/CY input data
+// =============================================================================
+func checkThalesCVVInput(pan, atn, scode string) error {
+	if len(pan) < 13 || len(pan) > 19 {
+		return fmt.Errorf("Invalid Primary Account Number (PAN) length: %d", len(pan))
+	}
+	if len(atn) != 4 {
+		return fmt.Errorf("Invalid Authentication Tracking Number (ATN) length: %d, expected: 4", len(atn))
+	}
+	if len(scode) != 3 {
+		return fmt.Errorf("Invalid Service Code length: %d, expected: 3", len(scode))
+	}
+	// Fields are copied into the command as is, delimiter must not be injected
+	if !isDigits(pan) || !isDigits(atn) || !isDigits(scode) {
+		return fmt.Errorf("Invalid payShield CVV input data: PAN, ATN & service code must be digits")
+	}
+	return nil
+}
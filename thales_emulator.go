@@ -0,0 +1,342 @@
+package gocavv
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	// LMK pair 14-15 variant 4 (CVK, key type 402)
+	THALES_LMK_VARIANT_CVK byte = 0xDE
+	// Double length key scheme 'U' variants (left & right key half)
+	THALES_LMK_VARIANT_U_LEFT  byte = 0xA6
+	THALES_LMK_VARIANT_U_RIGHT byte = 0x5A
+)
+
+// =============================================================================
+//  ThalesEmulator is an in-process payShield emulator implementing CW/CY host
+//  commands with the software CVV2 routine. Keys are encrypted under a test
+//  LMK pair 14-15 (16 bytes), it is intended for tests only.
+// =============================================================================
+type ThalesEmulator struct {
+	HeaderLen int
+
+	lmk      []byte
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// =============================================================================
+//  Create payShield emulator with test LMK pair 14-15
+// =============================================================================
+func NewThalesEmulator(lmk []byte) (*ThalesEmulator, error) {
+	if len(lmk) != 16 {
+		return nil, fmt.Errorf("Invalid LMK length: %d, expected: 16", len(lmk))
+	}
+	return &ThalesEmulator{
+		HeaderLen: THALES_DEFAULT_HEADER_LEN,
+		lmk:       append([]byte(nil), lmk...),
+		conns:     make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// =============================================================================
+//  Listen on address (e.g. "127.0.0.1:0") and serve in background
+// =============================================================================
+func (e *ThalesEmulator) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.listener = l
+	e.mu.Unlock()
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.acceptLoop(l)
+	}()
+	return nil
+}
+
+// =============================================================================
+//  Serve accepted connections until listener is closed
+// =============================================================================
+func (e *ThalesEmulator) Serve(l net.Listener) error {
+	e.mu.Lock()
+	e.listener = l
+	e.mu.Unlock()
+
+	return e.acceptLoop(l)
+}
+
+// =============================================================================
+//  Helper function to accept client connections
+// =============================================================================
+func (e *ThalesEmulator) acceptLoop(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		e.mu.Lock()
+		e.conns[conn] = struct{}{}
+		e.mu.Unlock()
+
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.serveConn(conn)
+		}()
+	}
+}
+
+// =============================================================================
+//  Emulator listening address
+// =============================================================================
+func (e *ThalesEmulator) Addr() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.listener == nil {
+		return ""
+	}
+	return e.listener.Addr().String()
+}
+
+// =============================================================================
+//  Stop emulator: close listener & all client connections
+// =============================================================================
+func (e *ThalesEmulator) Close() error {
+	var err error
+
+	e.mu.Lock()
+	if e.listener != nil {
+		err = e.listener.Close()
+	}
+	for conn := range e.conns {
+		conn.Close()
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	return err
+}
+
+// =============================================================================
+//  Encrypt CVK A & CVK B (8 bytes each) under LMK, returns 32H
+// =============================================================================
+func (e *ThalesEmulator) EncryptCVK(keyA, keyB []byte) (string, error) {
+	if len(keyA) != 8 || len(keyB) != 8 {
+		return "", fmt.Errorf("Invalid CVK A/B length: %d/%d, expected: 8", len(keyA), len(keyB))
+	}
+	ea, err := e.lmkCrypt(keyA, 0, false)
+	if err != nil {
+		return "", err
+	}
+	eb, err := e.lmkCrypt(keyB, 0, false)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(ea) + hex.EncodeToString(eb)), nil
+}
+
+// =============================================================================
+//  Encrypt double length CVK (16 bytes) under LMK, returns 'U' + 32H
+// =============================================================================
+func (e *ThalesEmulator) EncryptCVKDouble(key []byte) (string, error) {
+	if len(key) != 16 {
+		return "", fmt.Errorf("Invalid CVK length: %d, expected: 16", len(key))
+	}
+	el, err := e.lmkCrypt(key[:8], THALES_LMK_VARIANT_U_LEFT, false)
+	if err != nil {
+		return "", err
+	}
+	er, err := e.lmkCrypt(key[8:], THALES_LMK_VARIANT_U_RIGHT, false)
+	if err != nil {
+		return "", err
+	}
+	return "U" + strings.ToUpper(hex.EncodeToString(el)+hex.EncodeToString(er)), nil
+}
+
+// =============================================================================
+//  Helper function to encrypt/decrypt 8 bytes block under LMK 14-15 variant 4
+//  with optional double length key scheme variant
+// =============================================================================
+func (e *ThalesEmulator) lmkCrypt(block []byte, uvariant byte, decrypt bool) ([]byte, error) {
+	lmk := make([]byte, 16)
+	copy(lmk, e.lmk)
+	lmk[0] ^= THALES_LMK_VARIANT_CVK
+	lmk[8] ^= uvariant
+
+	c, err := createKeyCipher(lmk)
+	for i := range lmk {
+		lmk[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, 8)
+	if decrypt {
+		c.Decrypt(dst, block)
+	} else {
+		c.Encrypt(dst, block)
+	}
+	return dst, nil
+}
+
+// =============================================================================
+//  Helper function to decrypt CVK A/B from command data, returns rest of data
+// =============================================================================
+func (e *ThalesEmulator) decryptCVK(data string) ([]byte, []byte, string, string) {
+	var uvl, uvr byte
+
+	if strings.HasPrefix(data, "U") {
+		data = data[1:]
+		uvl, uvr = THALES_LMK_VARIANT_U_LEFT, THALES_LMK_VARIANT_U_RIGHT
+	}
+	if len(data) < 32 {
+		return nil, nil, "", THALES_ERR_KEY_LENGTH
+	}
+	ekey, err := hex.DecodeString(data[:32])
+	if err != nil {
+		return nil, nil, "", THALES_ERR_INPUT_DATA
+	}
+	keyA, err := e.lmkCrypt(ekey[:8], uvl, true)
+	if err != nil {
+		return nil, nil, "", THALES_ERR_INPUT_DATA
+	}
+	keyB, err := e.lmkCrypt(ekey[8:], uvr, true)
+	if err != nil {
+		return nil, nil, "", THALES_ERR_INPUT_DATA
+	}
+	if !desOddParity(keyA) || !desOddParity(keyB) {
+		return nil, nil, "", THALES_ERR_KEY_PARITY
+	}
+	return keyA, keyB, data[32:], THALES_ERR_OK
+}
+
+// =============================================================================
+//  Helper function to check DES key odd parity
+// =============================================================================
+func desOddParity(key []byte) bool {
+	for _, b := range key {
+		n := 0
+		for ; b > 0; b >>= 1 {
+			n += int(b & 1)
+		}
+		if n%2 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// =============================================================================
+//  Helper function to serve single client connection
+// =============================================================================
+func (e *ThalesEmulator) serveConn(conn net.Conn) {
+	defer func() {
+		e.mu.Lock()
+		delete(e.conns, conn)
+		e.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		msg, err := readThalesFrame(conn)
+		if err != nil {
+			return
+		}
+		if len(msg) < e.HeaderLen+2 {
+			return
+		}
+		header := string(msg[:e.HeaderLen])
+		cmd := string(msg[e.HeaderLen : e.HeaderLen+2])
+		rcode, ecode, out := e.execute(cmd, string(msg[e.HeaderLen+2:]))
+
+		if err := writeThalesFrame(conn, []byte(header+rcode+ecode+out)); err != nil {
+			return
+		}
+	}
+}
+
+// =============================================================================
+//  Helper function to execute host command, returns response & error code
+// =============================================================================
+func (e *ThalesEmulator) execute(cmd, data string) (string, string, string) {
+	switch cmd {
+	case "CW":
+		keyA, keyB, rest, ecode := e.decryptCVK(data)
+		if ecode != THALES_ERR_OK {
+			return "CX", ecode, ""
+		}
+		pan, atn, scode, ok := parseThalesCVVData(rest)
+		if !ok {
+			return "CX", THALES_ERR_INPUT_DATA, ""
+		}
+		cvv2, err := generateCVV2(pan, atn, scode, keyA, keyB)
+		if err != nil {
+			return "CX", THALES_ERR_INPUT_DATA, ""
+		}
+		return "CX", THALES_ERR_OK, fmt.Sprintf("%03d", cvv2)
+
+	case "CY":
+		keyA, keyB, rest, ecode := e.decryptCVK(data)
+		if ecode != THALES_ERR_OK {
+			return "CZ", ecode, ""
+		}
+		if len(rest) < 3 {
+			return "CZ", THALES_ERR_INPUT_DATA, ""
+		}
+		pan, atn, scode, ok := parseThalesCVVData(rest[3:])
+		if !ok {
+			return "CZ", THALES_ERR_INPUT_DATA, ""
+		}
+		valid, err := NewSoftwareCryptoProvider(keyA, keyB).VerifyCVV2(pan, atn, scode, atoiOrNegative(rest[:3]))
+		if err != nil {
+			return "CZ", THALES_ERR_INPUT_DATA, ""
+		}
+		if !valid {
+			return "CZ", THALES_ERR_VERIFY_FAILURE, ""
+		}
+		return "CZ", THALES_ERR_OK, ""
+	}
+	return thalesResponseCode(cmd), THALES_ERR_CMD_DISABLED, ""
+}
+
+// =============================================================================
+//  Helper function to parse PAN ';' expiry (4N) service code (3N)
+// =============================================================================
+func parseThalesCVVData(data string) (string, string, string, bool) {
+	i := strings.IndexByte(data, ';')
+	if i < 0 || len(data) != i+8 {
+		return "", "", "", false
+	}
+	for _, r := range data[:i] + data[i+1:] {
+		if r < '0' || r > '9' {
+			return "", "", "", false
+		}
+	}
+	return data[:i], data[i+1 : i+5], data[i+5:], true
+}
+
+// =============================================================================
+//  Helper function to convert numeric field, returns -1 on error
+// =============================================================================
+func atoiOrNegative(s string) int {
+	n := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
@@ -0,0 +1,160 @@
+package gocavv
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+)
+
+const (
+	TEST_THALES_LMK string = "0123456789ABCDEFFEDCBA9876543210"
+)
+
+// =============================================================================
+//  Helper function to start payShield emulator & client
+// =============================================================================
+func startThalesEmulator(t *testing.T) (*ThalesEmulator, *ThalesClient) {
+	lmk, _ := hex.DecodeString(TEST_THALES_LMK)
+	emu, err := NewThalesEmulator(lmk)
+	if err != nil {
+		t.Fatalf("[THALES]: Failed to create emulator: %s\n", err)
+	}
+	if err := emu.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("[THALES]: Failed to start emulator: %s\n", err)
+	}
+	return emu, NewThalesClient(emu.Addr())
+}
+
+// =============================================================================
+//  Test VISA CAVV generation & verification through payShield CW/CY commands
+// =============================================================================
+func TestThales_VisaCavv(t *testing.T) {
+	emu, client := startThalesEmulator(t)
+	defer emu.Close()
+	defer client.Close()
+
+	cvk, err := emu.EncryptCVK(keyAV, keyBV)
+	if err != nil {
+		t.Fatalf("[THALES]: Failed to encrypt CVK under LMK: %s\n", err)
+	}
+	p := NewThalesCryptoProvider(client, cvk)
+
+	cavv, err := GenerateVisaCavvWithProvider(TEST_V_PAN_16, TEST_V_I_ATN, TEST_V_I_AUTH_RC, TEST_V_I_SECOND_ACODE, TEST_V_I_CAVV_KEY_ID, p)
+	if err != nil {
+		t.Fatalf("[THALES]: Failed to generate CAVV: %s\n", err)
+	}
+	if scavv := hex.EncodeToString(cavv); scavv != TEST_V_RS_CAVV {
+		t.Fatalf("[THALES]: Invalid CAVV: %s\n\texpected: %s\n", scavv, TEST_V_RS_CAVV)
+	}
+
+	ok, err := VerifyVisaCavvWithProvider(TEST_V_PAN_16, cavv, p)
+	if err != nil || !ok {
+		t.Fatalf("[THALES]: Failed to verify CAVV: %v\n", err)
+	}
+	// Tamper CAVV output
+	cavv[4] ^= 0x01
+	ok, err = VerifyVisaCavvWithProvider(TEST_V_PAN_16, cavv, p)
+	if err != nil || ok {
+		t.Fatalf("[THALES]: Verified invalid CAVV: %v\n", err)
+	}
+}
+
+// =============================================================================
+//  Test double length CVK ('U' key scheme)
+// =============================================================================
+func TestThales_DoubleLengthCVK(t *testing.T) {
+	emu, client := startThalesEmulator(t)
+	defer emu.Close()
+	defer client.Close()
+
+	cvk, err := emu.EncryptCVKDouble(append(append([]byte{}, keyAV...), keyBV...))
+	if err != nil {
+		t.Fatalf("[THALES]: Failed to encrypt CVK under LMK: %s\n", err)
+	}
+	cvv2, err := NewThalesCryptoProvider(client, cvk).GenerateCVV2(TEST_V_PAN_16, TEST_V_S_ATN[12:], TEST_S_SERVICE_CODE)
+	if err != nil {
+		t.Fatalf("[THALES]: Failed to generate CVV2: %s\n", err)
+	}
+	if cvv2 != TEST_V_CVV2 {
+		t.Fatalf("[THALES]: Invalid CVV2: [%d] expected: [%d]\n", cvv2, TEST_V_CVV2)
+	}
+}
+
+// =============================================================================
+//  Test error code returned for disabled command & wrong key
+// =============================================================================
+func TestThales_Errors(t *testing.T) {
+	emu, client := startThalesEmulator(t)
+	defer emu.Close()
+	defer client.Close()
+
+	if _, err := client.Command("NC", nil); err == nil {
+		t.Fatalf("[THALES]: Unsupported command succeeded\n")
+	} else if e, ok := err.(*ThalesError); !ok || e.Code != THALES_ERR_CMD_DISABLED {
+		t.Fatalf("[THALES]: Invalid error for unsupported command: %v\n", err)
+	}
+
+	// Key encrypted under another LMK fails parity check
+	_, err := NewThalesCryptoProvider(client, "00000000000000000000000000000000").GenerateCVV2(TEST_V_PAN_16, TEST_V_S_ATN[12:], TEST_S_SERVICE_CODE)
+	if e, ok := err.(*ThalesError); !ok || e.Code != THALES_ERR_KEY_PARITY {
+		t.Fatalf("[THALES]: Invalid error for wrong CVK: %v\n", err)
+	}
+
+	// Delimiter & non digits are not copied into command
+	p := NewThalesCryptoProvider(client, "00000000000000000000000000000000")
+	if _, err := p.GenerateCVV2("4123456789012;45", TEST_V_S_ATN[12:], TEST_S_SERVICE_CODE); err == nil {
+		t.Fatalf("[THALES]: Accepted PAN with delimiter\n")
+	}
+	if _, err := p.VerifyCVV2(TEST_V_PAN_16, "12A4", TEST_S_SERVICE_CODE, 123); err == nil {
+		t.Fatalf("[THALES]: Accepted ATN with non digits\n")
+	}
+}
+
+// =============================================================================
+//  Test connection is dropped after response out of sync with request
+// =============================================================================
+func TestThales_ResponseMismatch(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("[THALES]: Failed to listen: %s\n", err)
+	}
+	defer l.Close()
+
+	// First connection answers with stale header, next ones echo the header
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn, stale bool) {
+				defer conn.Close()
+				for {
+					req, err := readThalesFrame(conn)
+					if err != nil {
+						return
+					}
+					header := string(req[:THALES_DEFAULT_HEADER_LEN])
+					if stale {
+						header = "9999"
+					}
+					if writeThalesFrame(conn, []byte(header+"ND00")) != nil {
+						return
+					}
+				}
+			}(conn, n == 0)
+		}
+	}()
+
+	client := NewThalesClient(l.Addr().String())
+	defer client.Close()
+	if _, err := client.Command("NC", nil); err == nil {
+		t.Fatalf("[THALES]: Accepted response with stale header\n")
+	}
+	if client.conn != nil {
+		t.Fatalf("[THALES]: Connection kept after response header mismatch\n")
+	}
+	if _, err := client.Command("NC", nil); err != nil {
+		t.Fatalf("[THALES]: Failed to reconnect after response mismatch: %s\n", err)
+	}
+}
@@ -0,0 +1,455 @@
+package gocavv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ANSI X9.143 / TR-31 key block:
+------------------------------------------------------------------------------------------------------------------------
+| Position |  Field Name                       |              Description                                 |  Length  |
+------------------------------------------------------------------------------------------------------------------------
+|    0     | Key Block Version ID              | B - TDES key derivation binding                          |    1     |
+|          |                                   | D - AES key derivation binding                           |          |
+------------------------------------------------------------------------------------------------------------------------
+|   1-4    | Key Block Length                  | Total key block length (decimal)                         |    4     |
+------------------------------------------------------------------------------------------------------------------------
+|   5-6    | Key Usage                         | C0 - Card Verification Key (CVK)                         |    2     |
+|          |                                   | M0-M8 - MAC keys (M7 - HMAC key)                         |          |
+------------------------------------------------------------------------------------------------------------------------
+|    7     | Algorithm                         | T - TDES, A - AES, H - HMAC                              |    1     |
+------------------------------------------------------------------------------------------------------------------------
+|    8     | Mode of Use                       | C - generate & verify, G - generate only,                |    1     |
+|          |                                   | V - verify only, N - no special restrictions             |          |
+------------------------------------------------------------------------------------------------------------------------
+|   9-10   | Key Version Number                | 00 if key versioning is not used                         |    2     |
+------------------------------------------------------------------------------------------------------------------------
+|    11    | Exportability                     | E - exportable, N - non-exportable, S - sensitive        |    1     |
+------------------------------------------------------------------------------------------------------------------------
+|  12-13   | Number of Optional Blocks         | Decimal                                                  |    2     |
+------------------------------------------------------------------------------------------------------------------------
+|  14-15   | Reserved                          | 00                                                       |    2     |
+------------------------------------------------------------------------------------------------------------------------
+|          | Optional Blocks                   | ID (2) + length (2H) + data                              |    var   |
+------------------------------------------------------------------------------------------------------------------------
+|          | Encrypted Key Data                | key length in bits (2 bytes) + key + padding, hex        |    var   |
+------------------------------------------------------------------------------------------------------------------------
+|          | MAC                               | B - 8 bytes, D - 16 bytes, hex                           |  16/32   |
+------------------------------------------------------------------------------------------------------------------------
+
+The key block encryption key (KBEK) and key block authentication key (KBAK) are derived from the key block
+protection key (KBPK) with CMAC. The MAC is calculated over the header and clear key data and is used as IV
+for the CBC encryption of key data.
+*/
+
+const (
+	TR31_VERSION_B byte = 'B'
+	TR31_VERSION_D byte = 'D'
+
+	TR31_USAGE_CVK  string = "C0"
+	TR31_USAGE_HMAC string = "M7"
+
+	TR31_ALG_TDES byte = 'T'
+	TR31_ALG_AES  byte = 'A'
+	TR31_ALG_HMAC byte = 'H'
+
+	TR31_MODE_GEN_VERIFY byte = 'C'
+	TR31_MODE_GENERATE   byte = 'G'
+	TR31_MODE_VERIFY     byte = 'V'
+	TR31_MODE_ANY        byte = 'N'
+
+	TR31_HEADER_LEN int = 16
+)
+
+// =============================================================================
+//  TR-31 optional block
+// =============================================================================
+type TR31OptionalBlock struct {
+	ID   string
+	Data string
+}
+
+// =============================================================================
+//  TR-31 key block header
+// =============================================================================
+type TR31Header struct {
+	Version        byte
+	KeyUsage       string
+	Algorithm      byte
+	ModeOfUse      byte
+	KeyVersion     string
+	Exportability  byte
+	OptionalBlocks []TR31OptionalBlock
+}
+
+// =============================================================================
+//  TR-31 key block with unwrapped clear key
+// =============================================================================
+type TR31KeyBlock struct {
+	Header TR31Header
+	Key    KeyBytes
+}
+
+// =============================================================================
+//  Wipe unwrapped key
+// =============================================================================
+func (kb *TR31KeyBlock) Destroy() {
+	kb.Key.Zeroize()
+	kb.Key = nil
+}
+
+// =============================================================================
+//  Unwrap TR-31 key block (version B or D) under key block protection key
+// =============================================================================
+func UnwrapTR31(kbpk []byte, block string) (*TR31KeyBlock, error) {
+
+	if len(block) < TR31_HEADER_LEN {
+		return nil, fmt.Errorf("Invalid TR-31 key block length: %d", len(block))
+	}
+	// Check key block length
+	blen, err := strconv.Atoi(block[1:5])
+	if err != nil || blen != len(block) {
+		return nil, fmt.Errorf("Invalid TR-31 key block length field: %q, actual: %d", block[1:5], len(block))
+	}
+	h, hlen, err := parseTR31Header(block)
+	if err != nil {
+		return nil, err
+	}
+	kbek, kbak, bs, maclen, err := deriveTR31Keys(h.Version, kbpk)
+	if err != nil {
+		return nil, err
+	}
+	if hlen%bs != 0 {
+		return nil, fmt.Errorf("Invalid TR-31 header length: %d, not multiple of %d", hlen, bs)
+	}
+	// Split encrypted key data & MAC
+	body := block[hlen:]
+	if len(body) < 2*(maclen+bs) {
+		return nil, fmt.Errorf("Invalid TR-31 key data length: %d", len(body))
+	}
+	enc, err := hex.DecodeString(body[:len(body)-2*maclen])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TR-31 encrypted key data: %s", err)
+	}
+	mac, err := hex.DecodeString(body[len(body)-2*maclen:])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TR-31 MAC: %s", err)
+	}
+	if len(enc)%bs != 0 {
+		return nil, fmt.Errorf("Invalid TR-31 encrypted key data length: %d", len(enc))
+	}
+	// Decrypt key data with MAC as IV
+	data := make([]byte, len(enc))
+	cipher.NewCBCDecrypter(kbek, mac[:bs]).CryptBlocks(data, enc)
+	// Verify MAC over header & clear key data
+	macInput := append([]byte(block[:hlen]), data...)
+	expected := cmac(kbak, macInput)
+	zeroBytes(macInput)
+	if subtle.ConstantTimeCompare(expected[:maclen], mac) != 1 {
+		zeroBytes(data)
+		return nil, fmt.Errorf("TR-31 key block MAC verification failed")
+	}
+	// Extract key
+	klen := (int(data[0])<<8 | int(data[1]))
+	if klen%8 != 0 || klen/8 > len(data)-2 || klen == 0 {
+		zeroBytes(data)
+		return nil, fmt.Errorf("Invalid TR-31 key length: %d bits", klen)
+	}
+	key := make([]byte, klen/8)
+	copy(key, data[2:])
+	zeroBytes(data)
+
+	return &TR31KeyBlock{Header: *h, Key: key}, nil
+}
+
+// =============================================================================
+//  Wrap clear key into TR-31 key block (version B or D) under key block
+//  protection key, header is padded with "PB" optional block if required
+// =============================================================================
+func WrapTR31(kbpk []byte, h TR31Header, key []byte) (string, error) {
+
+	kbek, kbak, bs, maclen, err := deriveTR31Keys(h.Version, kbpk)
+	if err != nil {
+		return "", err
+	}
+	if len(key) == 0 || len(key) > 0x1FFF {
+		return "", fmt.Errorf("Invalid TR-31 key length: %d", len(key))
+	}
+	if len(h.KeyUsage) != 2 || len(h.KeyVersion) != 2 {
+		return "", fmt.Errorf("Invalid TR-31 key usage %q or key version %q", h.KeyUsage, h.KeyVersion)
+	}
+	// Build optional blocks
+	var opt strings.Builder
+	nopt := len(h.OptionalBlocks)
+	for _, ob := range h.OptionalBlocks {
+		if len(ob.ID) != 2 || len(ob.Data)+4 > 0xFF {
+			return "", fmt.Errorf("Invalid TR-31 optional block: %q", ob.ID)
+		}
+		fmt.Fprintf(&opt, "%s%02X%s", ob.ID, len(ob.Data)+4, ob.Data)
+	}
+	// Pad header to cipher block size
+	if pad := (bs - (TR31_HEADER_LEN+opt.Len())%bs) % bs; pad > 0 {
+		if pad < 4 {
+			pad += bs
+		}
+		fmt.Fprintf(&opt, "PB%02X%s", pad, strings.Repeat("0", pad-4))
+		nopt++
+	}
+	// Clear key data: key length in bits, key, random padding
+	dlen := 2 + len(key)
+	dlen += (bs - dlen%bs) % bs
+	data := make([]byte, dlen)
+	data[0], data[1] = byte(len(key)*8>>8), byte(len(key)*8)
+	copy(data[2:], key)
+	if _, err := rand.Read(data[2+len(key):]); err != nil {
+		return "", err
+	}
+	defer zeroBytes(data)
+
+	total := TR31_HEADER_LEN + opt.Len() + 2*dlen + 2*maclen
+	header := fmt.Sprintf("%c%04d%s%c%c%s%c%02d00%s", h.Version, total, h.KeyUsage, h.Algorithm,
+		h.ModeOfUse, h.KeyVersion, h.Exportability, nopt, opt.String())
+
+	macInput := append([]byte(header), data...)
+	mac := cmac(kbak, macInput)[:maclen]
+	zeroBytes(macInput)
+	enc := make([]byte, dlen)
+	cipher.NewCBCEncrypter(kbek, mac[:bs]).CryptBlocks(enc, data)
+
+	return header + strings.ToUpper(hex.EncodeToString(enc)+hex.EncodeToString(mac)), nil
+}
+
+// =============================================================================
+//  Get copies of CVK A & CVK B from key block. Key usage must be C0 (TDES
+//  double length), mode of use must permit generation (ACS) or verification
+//  (issuer)
+// =============================================================================
+func (kb *TR31KeyBlock) CVK(generate bool) ([]byte, []byte, error) {
+	if kb.Header.KeyUsage != TR31_USAGE_CVK {
+		return nil, nil, fmt.Errorf("Invalid TR-31 key usage for CVK: %s, expected: %s", kb.Header.KeyUsage, TR31_USAGE_CVK)
+	}
+	if kb.Header.Algorithm != TR31_ALG_TDES || len(kb.Key) != 16 {
+		return nil, nil, fmt.Errorf("Invalid TR-31 CVK: algorithm %c, length %d", kb.Header.Algorithm, len(kb.Key))
+	}
+	if err := kb.checkModeOfUse(generate); err != nil {
+		return nil, nil, err
+	}
+	return append([]byte(nil), kb.Key[:8]...), append([]byte(nil), kb.Key[8:]...), nil
+}
+
+// =============================================================================
+//  Create crypto provider for CAVV / CVC2 from CVK key block
+// =============================================================================
+func (kb *TR31KeyBlock) CryptoProvider(generate bool) (*SoftwareCryptoProvider, error) {
+	keyA, keyB, err := kb.CVK(generate)
+	if err != nil {
+		return nil, err
+	}
+	return NewSoftwareCryptoProvider(keyA, keyB), nil
+}
+
+// =============================================================================
+//  Create MAC provider for Master Card AAV / IAV from HMAC key block (copy of
+//  key). Key usage must be M-family with HMAC algorithm
+// =============================================================================
+func (kb *TR31KeyBlock) MacProvider(generate bool) (*SoftwareMacProvider, error) {
+	if len(kb.Header.KeyUsage) != 2 || kb.Header.KeyUsage[0] != 'M' || kb.Header.Algorithm != TR31_ALG_HMAC {
+		return nil, fmt.Errorf("Invalid TR-31 key usage for HMAC: %s, algorithm %c", kb.Header.KeyUsage, kb.Header.Algorithm)
+	}
+	if err := kb.checkModeOfUse(generate); err != nil {
+		return nil, err
+	}
+	return NewSoftwareMacProvider(append([]byte(nil), kb.Key...)), nil
+}
+
+// =============================================================================
+//  Helper function to check mode of use for generation or verification
+// =============================================================================
+func (kb *TR31KeyBlock) checkModeOfUse(generate bool) error {
+	switch kb.Header.ModeOfUse {
+	case TR31_MODE_GEN_VERIFY, TR31_MODE_ANY:
+		return nil
+	case TR31_MODE_GENERATE:
+		if generate {
+			return nil
+		}
+	case TR31_MODE_VERIFY:
+		if !generate {
+			return nil
+		}
+	}
+	return fmt.Errorf("TR-31 key mode of use %c does not permit operation", kb.Header.ModeOfUse)
+}
+
+// =============================================================================
+//  Helper function to parse key block header, returns header length
+// =============================================================================
+func parseTR31Header(block string) (*TR31Header, int, error) {
+	h := &TR31Header{
+		Version:       block[0],
+		KeyUsage:      block[5:7],
+		Algorithm:     block[7],
+		ModeOfUse:     block[8],
+		KeyVersion:    block[9:11],
+		Exportability: block[11],
+	}
+	nopt, err := strconv.Atoi(block[12:14])
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid TR-31 number of optional blocks: %q", block[12:14])
+	}
+	pos := TR31_HEADER_LEN
+	for i := 0; i < nopt; i++ {
+		if len(block) < pos+4 {
+			return nil, 0, fmt.Errorf("Invalid TR-31 optional block %d", i+1)
+		}
+		id := block[pos : pos+2]
+		olen, err := strconv.ParseUint(block[pos+2:pos+4], 16, 8)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid TR-31 optional block %s length: %q", id, block[pos+2:pos+4])
+		}
+		start := pos + 4
+		// Extended length: length of length (2H), length
+		if olen == 0 {
+			if len(block) < pos+6 {
+				return nil, 0, fmt.Errorf("Invalid TR-31 optional block %s extended length", id)
+			}
+			ll, err := strconv.ParseUint(block[pos+4:pos+6], 16, 8)
+			if err != nil || len(block) < pos+6+int(ll)*2 {
+				return nil, 0, fmt.Errorf("Invalid TR-31 optional block %s extended length", id)
+			}
+			olen, err = strconv.ParseUint(block[pos+6:pos+6+int(ll)*2], 16, 32)
+			if err != nil {
+				return nil, 0, fmt.Errorf("Invalid TR-31 optional block %s extended length", id)
+			}
+			start = pos + 6 + int(ll)*2
+		}
+		if int(olen) < start-pos || len(block) < pos+int(olen) {
+			return nil, 0, fmt.Errorf("Invalid TR-31 optional block %s length: %d", id, olen)
+		}
+		h.OptionalBlocks = append(h.OptionalBlocks, TR31OptionalBlock{ID: id, Data: block[start : pos+int(olen)]})
+		pos += int(olen)
+	}
+	return h, pos, nil
+}
+
+// =============================================================================
+//  Helper function to derive KBEK & KBAK ciphers from KBPK, returns cipher
+//  block size & MAC length
+// =============================================================================
+func deriveTR31Keys(version byte, kbpk []byte) (cipher.Block, cipher.Block, int, int, error) {
+	var newCipher func([]byte) (cipher.Block, error)
+	var alg uint16
+	var maclen int
+
+	switch version {
+	case TR31_VERSION_B:
+		if len(kbpk) != 16 && len(kbpk) != 24 {
+			return nil, nil, 0, 0, fmt.Errorf("Invalid TR-31 TDES KBPK length: %d", len(kbpk))
+		}
+		newCipher, maclen = createKeyCipher, 8
+		alg = map[int]uint16{16: 0x0000, 24: 0x0001}[len(kbpk)]
+	case TR31_VERSION_D:
+		if len(kbpk) != 16 && len(kbpk) != 24 && len(kbpk) != 32 {
+			return nil, nil, 0, 0, fmt.Errorf("Invalid TR-31 AES KBPK length: %d", len(kbpk))
+		}
+		newCipher, maclen = aes.NewCipher, 16
+		alg = map[int]uint16{16: 0x0002, 24: 0x0003, 32: 0x0004}[len(kbpk)]
+	default:
+		return nil, nil, 0, 0, fmt.Errorf("Unsupported TR-31 key block version: %c", version)
+	}
+
+	c, err := newCipher(kbpk)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	derive := func(usage uint16) (cipher.Block, error) {
+		bits := len(kbpk) * 8
+		key := make([]byte, 0, len(kbpk)+c.BlockSize())
+		// Derivation data: counter, key usage, separator, algorithm, length
+		for i := byte(1); len(key) < len(kbpk); i++ {
+			dd := []byte{i, byte(usage >> 8), byte(usage), 0x00, byte(alg >> 8), byte(alg), byte(bits >> 8), byte(bits)}
+			key = append(key, cmac(c, dd)...)
+		}
+		defer zeroBytes(key)
+		return newCipher(key[:len(kbpk)])
+	}
+	kbek, err := derive(0x0000)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	kbak, err := derive(0x0001)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	return kbek, kbak, c.BlockSize(), maclen, nil
+}
+
+// =============================================================================
+//  Helper function to calculate CMAC (NIST SP 800-38B) for 64 & 128 bits
+//  block ciphers
+// =============================================================================
+func cmac(c cipher.Block, msg []byte) []byte {
+	bs := c.BlockSize()
+	rb := byte(0x87)
+	if bs == 8 {
+		rb = 0x1B
+	}
+	// Generate subkeys K1 & K2
+	shift := func(in []byte) []byte {
+		out := make([]byte, bs)
+		for i := 0; i < bs; i++ {
+			out[i] = in[i] << 1
+			if i+1 < bs {
+				out[i] |= in[i+1] >> 7
+			}
+		}
+		if in[0]&0x80 != 0 {
+			out[bs-1] ^= rb
+		}
+		return out
+	}
+	l := make([]byte, bs)
+	c.Encrypt(l, l)
+	k1 := shift(l)
+	k2 := shift(k1)
+
+	// Last block: complete - XOR K1, incomplete - pad 0x80 00.. & XOR K2
+	n := (len(msg) + bs - 1) / bs
+	last := make([]byte, bs)
+	if n > 0 && len(msg)%bs == 0 {
+		copy(last, msg[(n-1)*bs:])
+		for i := range last {
+			last[i] ^= k1[i]
+		}
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*bs:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		for i := range last {
+			last[i] ^= k2[i]
+		}
+	}
+	// CBC-MAC
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		for j := 0; j < bs; j++ {
+			x[j] ^= msg[i*bs+j]
+		}
+		c.Encrypt(x, x)
+	}
+	for j := 0; j < bs; j++ {
+		x[j] ^= last[j]
+	}
+	c.Encrypt(x, x)
+	return x
+}
@@ -0,0 +1,204 @@
+package gocavv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const (
+	TEST_TR31_KBPK_TDES string = "89E88CF7931444F334BD7547FC3F380C"
+	TEST_TR31_KBPK_AES  string = "88E1AB2A2E3DD38C1FA039A536500CC8A87AB9D62DC92C01058FA79F44657DE6"
+)
+
+// =============================================================================
+//  Test CMAC with NIST SP 800-38B examples (AES-128 & TDEA)
+// =============================================================================
+func TestTR31_CMAC(t *testing.T) {
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	c, _ := aes.NewCipher(key)
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172a")
+
+	if mac := hex.EncodeToString(cmac(c, nil)); mac != "bb1d6929e95937287fa37d129b756746" {
+		t.Fatalf("[TR31]: Invalid AES CMAC for empty message: %s\n", mac)
+	}
+	if mac := hex.EncodeToString(cmac(c, msg)); mac != "070a16b46b4d4144f79bdd9dd04a287c" {
+		t.Fatalf("[TR31]: Invalid AES CMAC: %s\n", mac)
+	}
+
+	key, _ = hex.DecodeString("8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5")
+	c, _ = createKeyCipher(key)
+	if mac := hex.EncodeToString(cmac(c, nil)); mac != "b7a688e122ffaf95" {
+		t.Fatalf("[TR31]: Invalid TDEA CMAC for empty message: %s\n", mac)
+	}
+	if mac := hex.EncodeToString(cmac(c, msg[:8])); mac != "8e8f293136283797" {
+		t.Fatalf("[TR31]: Invalid TDEA CMAC: %s\n", mac)
+	}
+}
+
+// =============================================================================
+//  Test unwrap of published key block examples (TR-31:2018 / X9.143): version
+//  B with TDES double length KBPK and version D with AES-256 KBPK, both carry
+//  the same AES-128 PIN encryption key
+// =============================================================================
+func TestTR31_KnownAnswer(t *testing.T) {
+	for _, v := range []struct {
+		kbpk  string
+		block string
+		alg   byte
+	}{
+		{"DD7515F2BFC17F85CE48F3CA25CB21F6",
+			"B0080P0TE00E000094B420079CC80BA3461F86FE26EFC4A3B8E4FA4C5F5341176EED7B727B8A248E", TR31_ALG_TDES},
+		{TEST_TR31_KBPK_AES,
+			"D0112P0AE00E0000B82679114F470F540165EDFBF7E250FCEA43F810D215F8D207E2E417C07156A27E8E31DA05F7425509593D03A457DC34", TR31_ALG_AES},
+	} {
+		kbpk, _ := hex.DecodeString(v.kbpk)
+		kb, err := UnwrapTR31(kbpk, v.block)
+		if err != nil {
+			t.Fatalf("[TR31]: Failed to unwrap published key block %c: %s\n", v.block[0], err)
+		}
+		if hex.EncodeToString(kb.Key) != "3f419e1cb7079442aa37474c2efbf8b8" {
+			t.Fatalf("[TR31]: Invalid key of published key block %c\n", v.block[0])
+		}
+		if kb.Header.KeyUsage != "P0" || kb.Header.Algorithm != v.alg || kb.Header.ModeOfUse != 'E' {
+			t.Fatalf("[TR31]: Invalid header of published key block %c: %+v\n", v.block[0], kb.Header)
+		}
+		// Wrap is randomized (padding): wrapped example must unwrap to the same key
+		again, err := WrapTR31(kbpk, kb.Header, kb.Key)
+		if err != nil || len(again) != len(v.block) || again[:TR31_HEADER_LEN] != v.block[:TR31_HEADER_LEN] {
+			t.Fatalf("[TR31]: Failed to wrap key of published key block: %s (%v)\n", again, err)
+		}
+		// Tampered MAC is rejected
+		tampered := v.block[:len(v.block)-1] + "0"
+		if v.block[len(v.block)-1] == '0' {
+			tampered = v.block[:len(v.block)-1] + "1"
+		}
+		if _, err := UnwrapTR31(kbpk, tampered); err == nil {
+			t.Fatalf("[TR31]: Unwrapped published key block %c with wrong MAC\n", v.block[0])
+		}
+	}
+}
+
+// =============================================================================
+//  Test wrap & unwrap CVK key block with version B and D
+// =============================================================================
+func TestTR31_WrapUnwrap(t *testing.T) {
+	cvk := append(append([]byte{}, keyAV...), keyBV...)
+	h := TR31Header{KeyUsage: TR31_USAGE_CVK, Algorithm: TR31_ALG_TDES, ModeOfUse: TR31_MODE_GEN_VERIFY,
+		KeyVersion: "00", Exportability: 'N'}
+
+	for _, v := range []struct {
+		version byte
+		kbpk    string
+	}{{TR31_VERSION_B, TEST_TR31_KBPK_TDES}, {TR31_VERSION_D, TEST_TR31_KBPK_AES}} {
+		kbpk, _ := hex.DecodeString(v.kbpk)
+		h.Version = v.version
+
+		block, err := WrapTR31(kbpk, h, cvk)
+		if err != nil {
+			t.Fatalf("[TR31]: Failed to wrap key block %c: %s\n", v.version, err)
+		}
+		if !strings.HasPrefix(block, string(v.version)) || !strings.Contains(block, "C0TC00N") {
+			t.Fatalf("[TR31]: Invalid key block header: %s\n", block)
+		}
+		kb, err := UnwrapTR31(kbpk, block)
+		if err != nil {
+			t.Fatalf("[TR31]: Failed to unwrap key block %c: %s\n", v.version, err)
+		}
+		if !bytes.Equal(kb.Key, cvk) {
+			t.Fatalf("[TR31]: Invalid unwrapped key: %X\n", kb.Key)
+		}
+		// Generate CAVV with unwrapped key
+		p, err := kb.CryptoProvider(true)
+		if err != nil {
+			t.Fatalf("[TR31]: Failed to create crypto provider: %s\n", err)
+		}
+		cavv, err := GenerateVisaCavvWithProvider(TEST_V_PAN_16, TEST_V_I_ATN, TEST_V_I_AUTH_RC, TEST_V_I_SECOND_ACODE, TEST_V_I_CAVV_KEY_ID, p)
+		if err != nil || hex.EncodeToString(cavv) != TEST_V_RS_CAVV {
+			t.Fatalf("[TR31]: Invalid CAVV with unwrapped key: %X, %v\n", cavv, err)
+		}
+		// Provider keeps its own copy of key
+		zeroBytes(kb.Key)
+		cavv, err = GenerateVisaCavvWithProvider(TEST_V_PAN_16, TEST_V_I_ATN, TEST_V_I_AUTH_RC, TEST_V_I_SECOND_ACODE, TEST_V_I_CAVV_KEY_ID, p)
+		if err != nil || hex.EncodeToString(cavv) != TEST_V_RS_CAVV {
+			t.Fatalf("[TR31]: Invalid CAVV after key block is wiped: %X, %v\n", cavv, err)
+		}
+		// Tamper header
+		tampered := block[:7] + "G" + block[8:]
+		if _, err := UnwrapTR31(kbpk, tampered); err == nil {
+			t.Fatalf("[TR31]: Unwrapped key block with modified header\n")
+		}
+	}
+}
+
+// =============================================================================
+//  Test optional blocks & header padding
+// =============================================================================
+func TestTR31_OptionalBlocks(t *testing.T) {
+	kbpk, _ := hex.DecodeString(TEST_TR31_KBPK_AES)
+	key, _ := hex.DecodeString("0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B0B")
+	h := TR31Header{Version: TR31_VERSION_D, KeyUsage: TR31_USAGE_HMAC, Algorithm: TR31_ALG_HMAC, ModeOfUse: TR31_MODE_GENERATE,
+		KeyVersion: "01", Exportability: 'N', OptionalBlocks: []TR31OptionalBlock{{ID: "KS", Data: "00604B120F9292800000"}}}
+
+	block, err := WrapTR31(kbpk, h, key)
+	if err != nil {
+		t.Fatalf("[TR31]: Failed to wrap key block: %s\n", err)
+	}
+	kb, err := UnwrapTR31(kbpk, block)
+	if err != nil {
+		t.Fatalf("[TR31]: Failed to unwrap key block: %s\n", err)
+	}
+	if len(kb.Header.OptionalBlocks) != 2 || kb.Header.OptionalBlocks[0] != h.OptionalBlocks[0] || kb.Header.OptionalBlocks[1].ID != "PB" {
+		t.Fatalf("[TR31]: Invalid optional blocks: %v\n", kb.Header.OptionalBlocks)
+	}
+	mp, err := kb.MacProvider(true)
+	if err != nil {
+		t.Fatalf("[TR31]: Failed to create MAC provider: %s\n", err)
+	}
+	mac, _ := NewSoftwareMacProvider(key).HMAC(crypto.SHA256, []byte(TEST_MC_MERCH_NAME))
+	zeroBytes(kb.Key)
+	if b, err := mp.HMAC(crypto.SHA256, []byte(TEST_MC_MERCH_NAME)); err != nil || !bytes.Equal(b, mac) {
+		t.Fatalf("[TR31]: Invalid HMAC after key block is wiped: %X (%v)\n", b, err)
+	}
+	kb.Header.KeyUsage = ""
+	if _, err := kb.MacProvider(true); err == nil {
+		t.Fatalf("[TR31]: Created MAC provider without key usage\n")
+	}
+}
+
+// =============================================================================
+//  Test key usage & mode of use enforcement
+// =============================================================================
+func TestTR31_KeyUsage(t *testing.T) {
+	kbpk, _ := hex.DecodeString(TEST_TR31_KBPK_TDES)
+	cvk := append(append([]byte{}, keyAV...), keyBV...)
+
+	// Verify only CVK can not be used to generate CAVV
+	h := TR31Header{Version: TR31_VERSION_B, KeyUsage: TR31_USAGE_CVK, Algorithm: TR31_ALG_TDES, ModeOfUse: TR31_MODE_VERIFY,
+		KeyVersion: "00", Exportability: 'N'}
+	block, _ := WrapTR31(kbpk, h, cvk)
+	kb, err := UnwrapTR31(kbpk, block)
+	if err != nil {
+		t.Fatalf("[TR31]: Failed to unwrap key block: %s\n", err)
+	}
+	if _, err := kb.CryptoProvider(true); err == nil {
+		t.Fatalf("[TR31]: Verify only CVK used for generation\n")
+	}
+	if _, err := kb.CryptoProvider(false); err != nil {
+		t.Fatalf("[TR31]: Failed to use CVK for verification: %s\n", err)
+	}
+	if _, err := kb.MacProvider(false); err == nil {
+		t.Fatalf("[TR31]: CVK used as HMAC key\n")
+	}
+
+	// Data encryption key can not be used as CVK
+	h.KeyUsage, h.ModeOfUse = "D0", 'B'
+	block, _ = WrapTR31(kbpk, h, cvk)
+	kb, _ = UnwrapTR31(kbpk, block)
+	if _, _, err := kb.CVK(true); err == nil {
+		t.Fatalf("[TR31]: Data encryption key used as CVK\n")
+	}
+}
@@ -1,6 +1,7 @@
 package gocavv
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 	"crypto/cipher"
@@ -32,6 +33,35 @@ func dec2bcd(i uint64) []byte {
 	}
 	return bcd
 }
+/********************************************************
+  Helper function to encode integer to BCD right justified
+  in n bytes (padded on the left with zeros)
+********************************************************/
+func dec2bcdPadded(i uint64, n int) []byte {
+	bcd := make([]byte, n)
+	for k := n - 1; k >= 0 && i > 0; k-- {
+		low := i % 10
+		i /= 10
+		hi := i % 10
+		i /= 10
+		bcd[k] = byte(hi<<4) | byte(low)
+	}
+	return bcd
+}
+/********************************************************
+  Helper function to decode BCD byte array to integer
+********************************************************/
+func bcd2dec(bcd []byte) (uint64, error) {
+	var x uint64
+	for _, b := range bcd {
+		hi, lo := uint64(b>>4), uint64(b&0x0f)
+		if hi > 9 || lo > 9 {
+			return 0, fmt.Errorf("Invalid BCD byte: 0x%02X", b)
+		}
+		x = 100*x + 10*hi + lo
+	}
+	return x, nil
+}
 /*
 func decodeBcd(bcd []byte) (x int, err error) {
 	for i, b := range bcd {
@@ -57,36 +87,36 @@ func decodeBcd2(bcd []byte) (int, error) {
 }
 */
 /********************************************************
-  Helper function to create cipher from key byte array
+  Helper function to create cipher from key byte array,
+  temporary key buffer is wiped
 ********************************************************/
 func createKeyCipher(key []byte) (cipher.Block, error) {
-	var err error
-	var cipher cipher.Block
-
-	/* Create cipher from keyA */
-	if len(key) != 24 {
-		var tripleDESKey []byte
-
-		if len(key) == 16 {
-			tripleDESKey = append(tripleDESKey, key[:16]...)
-			tripleDESKey = append(tripleDESKey, key[:8]...)
-		} else if len(key) == 8 {
-			tripleDESKey = append(tripleDESKey, key[:8]...)
-			tripleDESKey = append(tripleDESKey, key[:8]...)
-			tripleDESKey = append(tripleDESKey, key[:8]...)
-		} else {
-			return nil, des.KeySizeError(len(key))
-		}
-
-		cipher, err = des.NewTripleDESCipher(tripleDESKey)
 
-	} else {
-		cipher, err = des.NewTripleDESCipher(key)
+	switch len(key) {
+	case 8:
+		// Single length key: K1 = K2 = K3 is equivalent to DES
+		return des.NewCipher(key)
+	case 16:
+		// Double length key: K3 = K1
+		var tripleDESKey [24]byte
+		copy(tripleDESKey[:], key)
+		copy(tripleDESKey[16:], key[:8])
+		cipher, err := des.NewTripleDESCipher(tripleDESKey[:])
+		zeroBytes(tripleDESKey[:])
+		return cipher, err
+	case 24:
+		return des.NewTripleDESCipher(key)
 	}
-	/* Check return error */
-	if err != nil {
-		return nil, err
+	return nil, des.KeySizeError(len(key))
+}
+// =============================================================================
+//  Helper function to check string contains digits only
+// =============================================================================
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
-
-	return cipher, nil
-}
\ No newline at end of file
+	return true
+}
@@ -1,79 +1,248 @@
 package gocavv
 
 import (
-	"os"
 	"bytes"
 	"compress/zlib"
 	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
 	"github.com/beevik/etree"
 )
 
 const (
 	TDS_MSG_VER_1 string = "1.0.2"
+	// Maximum size of inflated 3-D Secure message
+	TDS_MSG_MAX_SIZE int = 1 << 20
 )
 
 
-func VeReqMarshalMessage() {
+/*
+VEReq message 3-D Secure 1.0.2:
+------------------------------------------------------------------------------------------------------------------------
+|  Element                  |  Description                                                          |   Format       |
+------------------------------------------------------------------------------------------------------------------------
+|  Message@id               |  Message identifier, echoed in VERes                                  |   1-128 chars  |
+------------------------------------------------------------------------------------------------------------------------
+|  version                  |  Protocol version (1.0.2)                                             |   string       |
+------------------------------------------------------------------------------------------------------------------------
+|  pan                      |  Primary Account Number                                               |   13-19 digits |
+------------------------------------------------------------------------------------------------------------------------
+|  Merchant/acqBIN          |  Acquirer BIN                                                         |   1-11 digits  |
+|  Merchant/merID           |  Merchant identifier assigned by acquirer                             |   1-24 chars   |
+|  Merchant/password        |  Merchant password (optional)                                         |   1-8 chars    |
+------------------------------------------------------------------------------------------------------------------------
+|  Browser/deviceCategory   |  0 - PC, 1 - mobile device (optional)                                 |   1 digit      |
+|  Browser/accept           |  Browser HTTP Accept header (optional)                                |   max 2048     |
+|  Browser/userAgent        |  Browser HTTP User-Agent header (optional)                            |   max 2048     |
+------------------------------------------------------------------------------------------------------------------------
+|  Extension                |  Extensions (optional, any number)                                    |   XML          |
+------------------------------------------------------------------------------------------------------------------------
+*/
 
-	doc := etree.NewDocument()
-	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
-	root := doc.CreateElement("ThreeDSecure")
-	msg := root.CreateElement("Message")
-	msg.CreateAttr("id", "13")
+// =============================================================================
+//  3-D Secure 1.0.2 message extension (inner XML content kept as is)
+// =============================================================================
+type ThreeDSExtension struct {
+	ID       string
+	Critical bool
+	Data     string
+}
+
+// =============================================================================
+//  VEReq merchant data
+// =============================================================================
+type VEReqMerchant struct {
+	AcqBIN   string
+	MerID    string
+	Password string
+}
+
+// =============================================================================
+//  VEReq browser data
+// =============================================================================
+type VEReqBrowser struct {
+	DeviceCategory string
+	Accept         string
+	UserAgent      string
+}
+
+// =============================================================================
+//  VEReq (Verify Enrollment Request) 3-D Secure 1.0.2
+// =============================================================================
+type VEReq struct {
+	MessageID  string
+	PAN        string
+	Merchant   VEReqMerchant
+	Browser    VEReqBrowser
+	Extensions []ThreeDSExtension
+}
+
+// =============================================================================
+//  Validate VEReq fields against 3-D Secure 1.0.2 schema
+// =============================================================================
+func (v *VEReq) Validate() error {
+	if len(v.MessageID) == 0 || len(v.MessageID) > 128 {
+		return fmt.Errorf("Invalid VEReq message id length: %d", len(v.MessageID))
+	}
+	if !isDigits(v.PAN) || len(v.PAN) < 13 || len(v.PAN) > 19 {
+		return fmt.Errorf("Invalid VEReq Primary Account Number (PAN) length: %d", len(v.PAN))
+	}
+	if !isDigits(v.Merchant.AcqBIN) || len(v.Merchant.AcqBIN) == 0 || len(v.Merchant.AcqBIN) > 11 {
+		return fmt.Errorf("Invalid VEReq acquirer BIN: %q", v.Merchant.AcqBIN)
+	}
+	if len(v.Merchant.MerID) == 0 || len(v.Merchant.MerID) > 24 {
+		return fmt.Errorf("Invalid VEReq merchant id length: %d", len(v.Merchant.MerID))
+	}
+	if len(v.Merchant.Password) > 8 {
+		return fmt.Errorf("Invalid VEReq merchant password length: %d", len(v.Merchant.Password))
+	}
+	if v.Browser.DeviceCategory != "" && v.Browser.DeviceCategory != "0" && v.Browser.DeviceCategory != "1" {
+		return fmt.Errorf("Invalid VEReq browser device category: %q", v.Browser.DeviceCategory)
+	}
+	if len(v.Browser.Accept) > 2048 || len(v.Browser.UserAgent) > 2048 {
+		return fmt.Errorf("Invalid VEReq browser accept/userAgent length: %d/%d", len(v.Browser.Accept), len(v.Browser.UserAgent))
+	}
+	return validateThreeDSExtensions(v.Extensions)
+}
+
+// =============================================================================
+//  Marshal VEReq to 3-D Secure 1.0.2 XML message
+// =============================================================================
+func (v *VEReq) Marshal() ([]byte, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	doc, msg := newThreeDSecureDocument(v.MessageID)
 	vq := msg.CreateElement("VEReq")
 	vq.CreateElement("version").SetText(TDS_MSG_VER_1)
-	vq.CreateElement("pan").SetText("4015500110472945")
+	vq.CreateElement("pan").SetText(v.PAN)
 	merch := vq.CreateElement("Merchant")
-	merch.CreateElement("acqBIN").SetText("2201380114")
-	merch.CreateElement("merID").SetText("07070707")
+	merch.CreateElement("acqBIN").SetText(v.Merchant.AcqBIN)
+	merch.CreateElement("merID").SetText(v.Merchant.MerID)
+	if v.Merchant.Password != "" {
+		merch.CreateElement("password").SetText(v.Merchant.Password)
+	}
 	brw := vq.CreateElement("Browser")
-	brw.CreateElement("deviceCategory").SetText("0")
-	brw.CreateElement("accept").SetText("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	brw.CreateElement("userAgent").SetText("Mozilla/5.0 (X11; Linux x86_64; rv:60.0) Gecko/20100101 Firefox/60.0")
+	if v.Browser.DeviceCategory != "" {
+		brw.CreateElement("deviceCategory").SetText(v.Browser.DeviceCategory)
+	}
+	if v.Browser.Accept != "" {
+		brw.CreateElement("accept").SetText(v.Browser.Accept)
+	}
+	if v.Browser.UserAgent != "" {
+		brw.CreateElement("userAgent").SetText(v.Browser.UserAgent)
+	}
+	if err := marshalThreeDSExtensions(vq, v.Extensions); err != nil {
+		return nil, err
+	}
+	return doc.WriteToBytes()
+}
 
-	doc.Indent(3)
-	doc.WriteTo(os.Stdout)
-	//doc.WriteToBytes()
+// =============================================================================
+//  Helper function to create ThreeDSecure document with Message element
+// =============================================================================
+func newThreeDSecureDocument(id string) (*etree.Document, *etree.Element) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	root := doc.CreateElement("ThreeDSecure")
+	msg := root.CreateElement("Message")
+	msg.CreateAttr("id", id)
+	return doc, msg
+}
 
-/*
-	type VeReq struct {
-		XMLName   	xml.Name `xml:"ThreeDSecure"`
-		//XMLName  xml.Name `xml:"ThreeDSecure>Message"`
-		Id        	int      `xml:"id,attr"`
+// =============================================================================
+//  Helper function to validate extensions
+// =============================================================================
+func validateThreeDSExtensions(exts []ThreeDSExtension) error {
+	for _, ext := range exts {
+		if len(ext.ID) == 0 || len(ext.ID) > 64 {
+			return fmt.Errorf("Invalid extension id length: %d", len(ext.ID))
+		}
+		if err := checkXMLWellFormed("<Extension>" + ext.Data + "</Extension>"); err != nil {
+			return fmt.Errorf("Invalid extension %s content: %s", ext.ID, err)
+		}
 	}
+	return nil
+}
 
-	v := &VeReq{Id:13}
+// =============================================================================
+//  Helper function to add extensions to message element
+// =============================================================================
+func marshalThreeDSExtensions(parent *etree.Element, exts []ThreeDSExtension) error {
+	for _, ext := range exts {
+		e := parent.CreateElement("Extension")
+		e.CreateAttr("id", ext.ID)
+		e.CreateAttr("critical", strconv.FormatBool(ext.Critical))
+		if ext.Data == "" {
+			continue
+		}
+		d := etree.NewDocument()
+		if err := d.ReadFromString("<Extension>" + ext.Data + "</Extension>"); err != nil {
+			return fmt.Errorf("Invalid extension %s content: %s", ext.ID, err)
+		}
+		for _, t := range d.Root().Child {
+			e.AddChild(t)
+		}
+	}
+	return nil
+}
 
-	enc := xml.NewEncoder(os.Stdout)
-	enc.Indent("  ", "    ")
-	if err := enc.Encode(v); err != nil {
-		fmt.Printf("error: %v\n", err)
+// =============================================================================
+//  Helper function to check XML fragment is well formed
+// =============================================================================
+func checkXMLWellFormed(s string) error {
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
 	}
-*/
 }
 
-func PaResUnMarshalMessage(paresB64 string) (error) {
-	data, err := base64.StdEncoding.DecodeString(paresB64)
+// =============================================================================
+//  Package 3-D Secure message for browser POST (PaReq / PaRes form fields):
+//  zlib deflate & base64 encoding
+// =============================================================================
+func PackThreeDSMessage(data []byte) (string, error) {
+	var b bytes.Buffer
+
+	w := zlib.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// =============================================================================
+//  Unpackage 3-D Secure message received from browser POST: base64 decoding
+//  & zlib inflate (message size is limited to TDS_MSG_MAX_SIZE)
+// =============================================================================
+func UnpackThreeDSMessage(b64 string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	b := bytes.NewReader(data)
-	r, err := zlib.NewReader(b)
+	r, err := zlib.NewReader(bytes.NewReader(data))
 	if err != nil {
-		return err
+		return nil, err
 	}
-/*
-	doc := etree.NewDocument()
-	n, err := doc.ReadFrom(r)
+	defer r.Close()
+
+	msg, err := ioutil.ReadAll(io.LimitReader(r, int64(TDS_MSG_MAX_SIZE)+1))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	doc.Indent(3)
-	doc.WriteTo(os.Stdout)
-*/
-	//io.Copy(os.Stdout, r)
-	// Output: hello, world
-	r.Close()
-	return nil
+	if len(msg) > TDS_MSG_MAX_SIZE {
+		return nil, fmt.Errorf("3-D Secure message exceeds maximum size: %d", TDS_MSG_MAX_SIZE)
+	}
+	return msg, nil
 }
-
@@ -0,0 +1,208 @@
+package gocavv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+/*
+VERes message 3-D Secure 1.0.2:
+------------------------------------------------------------------------------------------------------------------------
+|  Element                  |  Description                                                          |   Format       |
+------------------------------------------------------------------------------------------------------------------------
+|  Message@id               |  Message identifier of VEReq                                          |   1-128 chars  |
+------------------------------------------------------------------------------------------------------------------------
+|  CH/enrolled              |  Y - Authentication available, N - Cardholder not participating,      |   1 char       |
+|                           |  U - Unable to authenticate                                           |                |
+------------------------------------------------------------------------------------------------------------------------
+|  CH/acctID                |  Account identifier to be used in PAReq (required if enrolled = Y)    |   1-28 chars   |
+------------------------------------------------------------------------------------------------------------------------
+|  url                      |  ACS URL (required if enrolled = Y)                                   |   max 2048     |
+------------------------------------------------------------------------------------------------------------------------
+|  protocol                 |  Protocols supported by ACS (ThreeDSecure, ...)                       |   0..n         |
+------------------------------------------------------------------------------------------------------------------------
+
+Error message codes:
+------------------------------------------------------------------------------------------------------------------------
+|   1  | Root element invalid                  |   4  | Critical element not recognized                              |
+|   2  | Message element not a defined message |   5  | Format of one or more elements is invalid                    |
+|   3  | Required element missing              |   6  | Protocol version too old                                     |
+|  98  | Transient system failure              |  99  | Permanent system failure                                     |
+------------------------------------------------------------------------------------------------------------------------
+*/
+
+const (
+	TDS_ENROLLED_Y string = "Y"
+	TDS_ENROLLED_N string = "N"
+	TDS_ENROLLED_U string = "U"
+)
+
+// =============================================================================
+//  3-D Secure 1.0.2 Error message returned instead of response
+// =============================================================================
+type ThreeDSError struct {
+	MessageID  string
+	Code       int
+	Message    string
+	Detail     string
+	VendorCode string
+}
+
+func (e *ThreeDSError) Error() string {
+	return fmt.Sprintf("3-D Secure error %d: %s (%s)", e.Code, e.Message, e.Detail)
+}
+
+// =============================================================================
+//  VERes (Verify Enrollment Response) 3-D Secure 1.0.2
+// =============================================================================
+type VERes struct {
+	MessageID  string
+	Version    string
+	Enrolled   string
+	AcctID     string
+	URL        string
+	Protocols  []string
+	Extensions []ThreeDSExtension
+}
+
+// =============================================================================
+//  Parse VERes received from Directory Server for VEReq. Error message is
+//  returned as *ThreeDSError
+// =============================================================================
+func UnmarshalVERes(data []byte, req *VEReq) (*VERes, error) {
+	id, vr, err := parseThreeDSecureMessage(data, "VERes")
+	if err != nil {
+		return nil, err
+	}
+	if req != nil && id != req.MessageID {
+		return nil, fmt.Errorf("VERes message id %q does not match VEReq message id %q", id, req.MessageID)
+	}
+
+	r := &VERes{
+		MessageID: id,
+		Version:   childText(vr, "version"),
+		Enrolled:  childText(vr, "CH/enrolled"),
+		AcctID:    childText(vr, "CH/acctID"),
+		URL:       childText(vr, "url"),
+	}
+	for _, p := range vr.SelectElements("protocol") {
+		r.Protocols = append(r.Protocols, strings.TrimSpace(p.Text()))
+	}
+	if r.Extensions, err = unmarshalThreeDSExtensions(vr); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// =============================================================================
+//  Validate VERes fields for enrollment status
+// =============================================================================
+func (r *VERes) Validate() error {
+	if r.Version != TDS_MSG_VER_1 {
+		return fmt.Errorf("Unsupported VERes version: %q", r.Version)
+	}
+	switch r.Enrolled {
+	case TDS_ENROLLED_Y:
+		if r.URL == "" || r.AcctID == "" {
+			return fmt.Errorf("VERes with enrolled status Y requires url & acctID")
+		}
+	case TDS_ENROLLED_N, TDS_ENROLLED_U:
+	default:
+		return fmt.Errorf("Invalid VERes enrolled status: %q", r.Enrolled)
+	}
+	if len(r.AcctID) > 28 || len(r.URL) > 2048 {
+		return fmt.Errorf("Invalid VERes acctID/url length: %d/%d", len(r.AcctID), len(r.URL))
+	}
+	return nil
+}
+
+// =============================================================================
+//  Check if authentication is available (cardholder enrolled)
+// =============================================================================
+func (r *VERes) IsEnrolled() bool {
+	return r.Enrolled == TDS_ENROLLED_Y
+}
+
+// =============================================================================
+//  Helper function to parse ThreeDSecure document, returns message id and
+//  element of expected message type. Error message is returned as error
+// =============================================================================
+func parseThreeDSecureMessage(data []byte, name string) (string, *etree.Element, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return "", nil, err
+	}
+	root := doc.Root()
+	if root == nil || root.Tag != "ThreeDSecure" {
+		return "", nil, fmt.Errorf("Invalid 3-D Secure root element")
+	}
+	msg := root.SelectElement("Message")
+	if msg == nil {
+		return "", nil, fmt.Errorf("3-D Secure Message element is missing")
+	}
+	id := msg.SelectAttrValue("id", "")
+
+	if e := msg.SelectElement("Error"); e != nil {
+		code, _ := strconv.Atoi(childText(e, "errorCode"))
+		return id, nil, &ThreeDSError{
+			MessageID:  id,
+			Code:       code,
+			Message:    childText(e, "errorMessage"),
+			Detail:     childText(e, "errorDetail"),
+			VendorCode: childText(e, "vendorCode"),
+		}
+	}
+	el := msg.SelectElement(name)
+	if el == nil {
+		return id, nil, fmt.Errorf("3-D Secure %s element is missing", name)
+	}
+	return id, el, nil
+}
+
+// =============================================================================
+//  Helper function to get trimmed text of child element by path
+// =============================================================================
+func childText(e *etree.Element, path string) string {
+	if c := e.FindElement(path); c != nil {
+		return strings.TrimSpace(c.Text())
+	}
+	return ""
+}
+
+// =============================================================================
+//  Helper function to unmarshal extensions of message element
+// =============================================================================
+func unmarshalThreeDSExtensions(parent *etree.Element) ([]ThreeDSExtension, error) {
+	var exts []ThreeDSExtension
+
+	for _, e := range parent.SelectElements("Extension") {
+		ext := ThreeDSExtension{ID: e.SelectAttrValue("id", ""), Critical: e.SelectAttrValue("critical", "false") == "true"}
+		// Serialize content inside of wrapper element
+		x := etree.NewElement("x")
+		for _, t := range e.Child {
+			switch v := t.(type) {
+			case *etree.Element:
+				x.AddChild(v.Copy())
+			case *etree.CharData:
+				x.CreateCharData(v.Data)
+			}
+		}
+		d := etree.NewDocument()
+		d.SetRoot(x)
+		s, err := d.WriteToString()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(s, "<x>") {
+			ext.Data = strings.TrimSuffix(strings.TrimPrefix(s, "<x>"), "</x>")
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
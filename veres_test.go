@@ -0,0 +1,64 @@
+package gocavv
+
+import (
+	"testing"
+)
+
+const (
+	TEST_VERES_Y string = `<?xml version="1.0" encoding="UTF-8"?><ThreeDSecure><Message id="13"><VERes><version>1.0.2</version>` +
+		`<CH><enrolled>Y</enrolled><acctID>AXNbDKFbbndI+24FUtr+K+oO2Hh6</acctID></CH><url>https://acs.example.com/pareq</url>` +
+		`<protocol>ThreeDSecure</protocol><Extension id="ext.1" critical="false"><data>1</data></Extension></VERes></Message></ThreeDSecure>`
+	TEST_VERES_N string = `<ThreeDSecure><Message id="13"><VERes><version>1.0.2</version><CH><enrolled>N</enrolled></CH></VERes></Message></ThreeDSecure>`
+	TEST_VERES_ERR string = `<ThreeDSecure><Message id="13"><Error><version>1.0.2</version><errorCode>5</errorCode>` +
+		`<errorMessage>Invalid format</errorMessage><errorDetail>pan</errorDetail></Error></Message></ThreeDSecure>`
+)
+
+// =============================================================================
+// Test VERes parsing for enrolled cardholder
+// =============================================================================
+func TestVEResEnrolled(t *testing.T) {
+	r, err := UnmarshalVERes([]byte(TEST_VERES_Y), testVEReq())
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to parse VERes: %s\n", err)
+	}
+	if !r.IsEnrolled() || r.AcctID != "AXNbDKFbbndI+24FUtr+K+oO2Hh6" || r.URL != "https://acs.example.com/pareq" {
+		t.Fatalf("[3DS]: Invalid VERes: %+v\n", r)
+	}
+	if len(r.Protocols) != 1 || r.Protocols[0] != "ThreeDSecure" {
+		t.Fatalf("[3DS]: Invalid VERes protocols: %v\n", r.Protocols)
+	}
+	if len(r.Extensions) != 1 || r.Extensions[0].ID != "ext.1" || r.Extensions[0].Data != "<data>1</data>" {
+		t.Fatalf("[3DS]: Invalid VERes extensions: %+v\n", r.Extensions)
+	}
+}
+// =============================================================================
+// Test VERes parsing for not enrolled cardholder & message id check
+// =============================================================================
+func TestVEResNotEnrolled(t *testing.T) {
+	r, err := UnmarshalVERes([]byte(TEST_VERES_N), testVEReq())
+	if err != nil {
+		t.Fatalf("[3DS]: Failed to parse VERes: %s\n", err)
+	}
+	if r.IsEnrolled() || r.Enrolled != TDS_ENROLLED_N {
+		t.Fatalf("[3DS]: Invalid VERes enrolled status: %s\n", r.Enrolled)
+	}
+
+	req := testVEReq()
+	req.MessageID = "14"
+	if _, err := UnmarshalVERes([]byte(TEST_VERES_N), req); err == nil {
+		t.Fatalf("[3DS]: Parsed VERes with wrong message id\n")
+	}
+}
+// =============================================================================
+// Test VERes Error message
+// =============================================================================
+func TestVEResError(t *testing.T) {
+	_, err := UnmarshalVERes([]byte(TEST_VERES_ERR), testVEReq())
+	e, ok := err.(*ThreeDSError)
+	if !ok {
+		t.Fatalf("[3DS]: Invalid error type for Error message: %v\n", err)
+	}
+	if e.Code != 5 || e.Detail != "pan" || e.MessageID != "13" {
+		t.Fatalf("[3DS]: Invalid Error message: %+v\n", e)
+	}
+}
@@ -96,6 +96,14 @@ Table D–7: Assembling CAVV Data Field
 ------------------------------------------------------------------------------------------------------------------------
 */
 
+const (
+	// Authentication Results Code (Table D-2)
+	VISA_ARC_AUTHENTICATED uint8 = 0
+	VISA_ARC_NOT_PERFORMED uint8 = 5
+	VISA_ARC_ATTEMPTS      uint8 = 7
+	VISA_ARC_FAILED        uint8 = 9
+)
+
 // ===================================================================================================
 //  VISA: to calculate CAVV value (using CVV2 with ATN)
 //
@@ -124,8 +132,18 @@ func GenerateVisaCavv(pan string, /* Primary Account Number (PAN) */
 	arc uint8, sacode, keyID uint8,
 	keyA, keyB []byte) ([]byte, error) {
 
+	return GenerateVisaCavvWithProvider(pan, iatn, arc, sacode, keyID, NewSoftwareCryptoProvider(keyA, keyB))
+}
+// ===================================================================================================
+//  VISA: to calculate CAVV value with CVV2 output produced by crypto provider (software, HSM)
+// ===================================================================================================
+func GenerateVisaCavvWithProvider(pan string, /* Primary Account Number (PAN) */
+	iatn uint, /* 16-digit number ATN */
+	arc uint8, sacode, keyID uint8,
+	p CryptoProvider) ([]byte, error) {
+
 	// Check Authentication Results Code
-	if arc > 9 || arc < 0 {
+	if arc > 9 {
 		return nil, fmt.Errorf("Invalid Authentication Results Code: %d", arc)
 	}
 	// Convert atn as integer to string
@@ -139,7 +157,7 @@ func GenerateVisaCavv(pan string, /* Primary Account Number (PAN) */
 	// Create service code from Authentication Results Code & Second Factor
 	scode := fmt.Sprintf("%1d%02d", arc, sacode)
 	// Generate CVV2 output
-	cvv2, err := generateCVV2(pan, atn[alen-4:], scode, keyA, keyB)
+	cvv2, err := p.GenerateCVV2(pan, atn[alen-4:], scode)
 	if err != nil {
 		return nil, err
 	}
@@ -153,10 +171,10 @@ func GenerateVisaCavv(pan string, /* Primary Account Number (PAN) */
 	// Set CAVV Key Indicator
 	cavv[2] = dec2bcd(uint64(keyID))[0]
 	// Set CAVV output
-	copy(cavv[3:], dec2bcd(uint64(cvv2))[:2])
+	copy(cavv[3:], dec2bcdPadded(uint64(cvv2), 2))
 	// Set Unpredictable Number
 	atn4digit, _ := strconv.Atoi(atn[alen-4:])
-	copy(cavv[5:], dec2bcd(uint64(atn4digit))[:2])
+	copy(cavv[5:], dec2bcdPadded(uint64(atn4digit), 2))
 	// Set ATN
 	copy(cavv[7:], dec2bcd(uint64(iatn))[:8])
 	// Set Version and Authentication Action
@@ -164,3 +182,109 @@ func GenerateVisaCavv(pan string, /* Primary Account Number (PAN) */
 
 	return cavv, nil
 }
+// ===================================================================================================
+//  VISA: to verify CAVV value (20 bytes) received in the authorization message
+// ===================================================================================================
+func VerifyVisaCavv(pan string, cavv []byte, keyA, keyB []byte) (bool, error) {
+	return VerifyVisaCavvWithProvider(pan, cavv, NewSoftwareCryptoProvider(keyA, keyB))
+}
+// ===================================================================================================
+//  VISA: to verify CAVV value with CVV2 output checked by crypto provider (software, HSM)
+// ===================================================================================================
+func VerifyVisaCavvWithProvider(pan string, cavv []byte, p CryptoProvider) (bool, error) {
+
+	d, err := ParseVisaCavv(cavv)
+	if err != nil {
+		return false, err
+	}
+	atn := fmt.Sprintf("%016d", d.ATN)
+	// Unpredictable Number must be the four least significant digits of the ATN
+	if fmt.Sprintf("%04d", d.UN) != atn[12:] {
+		return false, nil
+	}
+	// Create service code from Authentication Results Code & Second Factor
+	scode := fmt.Sprintf("%1d%02d", d.ARC, d.SecondFactor)
+
+	return p.VerifyCVV2(pan, atn[12:], scode, d.CVV2)
+}
+// ===================================================================================================
+//  VISA CAVV fields (Table D-7)
+// ===================================================================================================
+type VisaCavvData struct {
+	ARC          uint8  /* Authentication Results Code       */
+	SecondFactor uint8  /* Second Factor Authentication Code */
+	KeyID        uint8  /* CAVV Key Indicator                */
+	CVV2         int    /* CAVV Output                       */
+	UN           uint16 /* Unpredictable Number              */
+	ATN          uint   /* Authentication Tracking Number    */
+	Version      uint8  /* Version and Authentication Action */
+}
+// ===================================================================================================
+//  VISA: to decode CAVV value (20 bytes)
+// ===================================================================================================
+func ParseVisaCavv(cavv []byte) (*VisaCavvData, error) {
+
+	if len(cavv) != 20 {
+		return nil, fmt.Errorf("Invalid CAVV length: %d, expected: 20", len(cavv))
+	}
+	// Get Authentication Results Code
+	arc, err := bcd2dec(cavv[:1])
+	if err != nil || arc > 9 {
+		return nil, fmt.Errorf("Invalid Authentication Results Code: 0x%02X", cavv[0])
+	}
+	// Get Second Factor Authentication Code
+	sacode, err := bcd2dec(cavv[1:2])
+	if err != nil {
+		return nil, err
+	}
+	// Get CAVV Key Indicator
+	keyID, err := bcd2dec(cavv[2:3])
+	if err != nil {
+		return nil, err
+	}
+	// Get CAVV output
+	cvv2, err := bcd2dec(cavv[3:5])
+	if err != nil || cvv2 > 999 {
+		return nil, fmt.Errorf("Invalid CAVV output: %02X%02X", cavv[3], cavv[4])
+	}
+	// Get Unpredictable Number
+	un, err := bcd2dec(cavv[5:7])
+	if err != nil {
+		return nil, err
+	}
+	// Get ATN
+	iatn, err := bcd2dec(cavv[7:15])
+	if err != nil {
+		return nil, err
+	}
+	// Get Version and Authentication Action
+	ver, err := bcd2dec(cavv[15:16])
+	if err != nil {
+		return nil, err
+	}
+	return &VisaCavvData{
+		ARC:          uint8(arc),
+		SecondFactor: uint8(sacode),
+		KeyID:        uint8(keyID),
+		CVV2:         int(cvv2),
+		UN:           uint16(un),
+		ATN:          uint(iatn),
+		Version:      uint8(ver),
+	}, nil
+}
+// ===================================================================================================
+//  VISA: Authentication Results Code for transaction status (Table D-2)
+// ===================================================================================================
+func VisaAuthResultsCode(status string) (uint8, error) {
+	switch status {
+	case EMV3DS_TRANS_STATUS_Y:
+		return VISA_ARC_AUTHENTICATED, nil
+	case EMV3DS_TRANS_STATUS_A:
+		return VISA_ARC_ATTEMPTS, nil
+	case EMV3DS_TRANS_STATUS_U:
+		return VISA_ARC_NOT_PERFORMED, nil
+	case EMV3DS_TRANS_STATUS_N, EMV3DS_TRANS_STATUS_R:
+		return VISA_ARC_FAILED, nil
+	}
+	return 0, fmt.Errorf("Invalid transaction status: %q", status)
+}
@@ -0,0 +1,129 @@
+package gocavv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+/*
+3-D Secure 1.0.2 transaction identifier (XID): 20 bytes assigned by the merchant plug-in, sent base64 encoded
+(28 chars) in PAReq Purchase/xid, echoed by ACS in PARes and submitted in the authorization message with the
+CAVV / AAV (VISA field 126.8, Master Card DE 48 subelement 43 for SPA 1 with AAV).
+*/
+
+const (
+	TDS_XID_LEN int = 20
+)
+
+// =============================================================================
+//  Generate random transaction identifier (20 bytes, base64)
+// =============================================================================
+func GenerateXID() (string, error) {
+	xid := make([]byte, TDS_XID_LEN)
+	if _, err := rand.Read(xid); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(xid), nil
+}
+
+// =============================================================================
+//  Decode base64 transaction identifier, returns 20 bytes
+// =============================================================================
+func DecodeXID(xid string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(xid)
+	if err != nil || len(b) != TDS_XID_LEN {
+		return nil, fmt.Errorf("Invalid xid: %q", xid)
+	}
+	return b, nil
+}
+
+// =============================================================================
+//  Check transaction identifiers are equal (compared decoded)
+// =============================================================================
+func MatchXID(a, b string) bool {
+	xa, err := DecodeXID(a)
+	if err != nil {
+		return false
+	}
+	xb, err := DecodeXID(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(xa, xb)
+}
+
+// =============================================================================
+//  Check PARes correlates with stored PAReq: message id, xid, merchant &
+//  purchase data must be echoed unchanged
+// =============================================================================
+func CheckPAResCorrelation(req *PAReq, res *PARes) error {
+	if !MatchXID(req.Purchase.XID, res.Purchase.XID) {
+		return fmt.Errorf("PARes xid %q does not match PAReq xid %q", res.Purchase.XID, req.Purchase.XID)
+	}
+	if res.MessageID != req.MessageID {
+		return fmt.Errorf("PARes message id %q does not match PAReq message id %q", res.MessageID, req.MessageID)
+	}
+	if res.Merchant.AcqBIN != req.Merchant.AcqBIN || res.Merchant.MerID != req.Merchant.MerID {
+		return fmt.Errorf("PARes merchant %s/%s does not match PAReq merchant %s/%s",
+			res.Merchant.AcqBIN, res.Merchant.MerID, req.Merchant.AcqBIN, req.Merchant.MerID)
+	}
+	if res.Purchase.PurchAmount != req.Purchase.PurchAmount || res.Purchase.Currency != req.Purchase.Currency ||
+		res.Purchase.Exponent != req.Purchase.Exponent {
+		return fmt.Errorf("PARes purchase amount %d %s does not match PAReq amount %d %s",
+			res.Purchase.PurchAmount, res.Purchase.Currency, req.Purchase.PurchAmount, req.Purchase.Currency)
+	}
+	if !res.Purchase.Date.Equal(req.Purchase.Date) {
+		return fmt.Errorf("PARes purchase date %s does not match PAReq date %s", res.Purchase.Date, req.Purchase.Date)
+	}
+	return nil
+}
+
+// =============================================================================
+//  3-D Secure data submitted in the authorization message
+// =============================================================================
+type ThreeDSAuthorizationData struct {
+	XID           []byte
+	CAVV          []byte
+	ECI           string
+	CAVVAlgorithm uint8
+}
+
+// =============================================================================
+//  Authorization data of authenticated (status Y) or attempted (status A)
+//  PARes
+// =============================================================================
+func (r *PARes) AuthorizationData() (*ThreeDSAuthorizationData, error) {
+	if r.TX.Status != TDS_TX_STATUS_Y && r.TX.Status != TDS_TX_STATUS_A {
+		return nil, fmt.Errorf("PARes with status %s has no authentication value", r.TX.Status)
+	}
+	xid, err := DecodeXID(r.Purchase.XID)
+	if err != nil {
+		return nil, err
+	}
+	return &ThreeDSAuthorizationData{
+		XID:           xid,
+		CAVV:          append([]byte(nil), r.TX.CAVV...),
+		ECI:           r.TX.ECI,
+		CAVVAlgorithm: r.TX.CAVVAlgorithm,
+	}, nil
+}
+
+// =============================================================================
+//  Check authorization data was issued for transaction with xid (base64)
+// =============================================================================
+func (a *ThreeDSAuthorizationData) MatchXID(xid string) bool {
+	b, err := DecodeXID(xid)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a.XID, b)
+}
+
+// =============================================================================
+//  Transaction identifier (base64) of authorization data
+// =============================================================================
+func (a *ThreeDSAuthorizationData) XIDString() string {
+	return base64.StdEncoding.EncodeToString(a.XID)
+}
@@ -0,0 +1,71 @@
+package gocavv
+
+import (
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Test XID generation
+// =============================================================================
+func TestXIDGenerate(t *testing.T) {
+	a, err := GenerateXID()
+	if err != nil {
+		t.Fatalf("[XID]: Failed to generate xid: %s\n", err)
+	}
+	if len(a) != 28 {
+		t.Fatalf("[XID]: Invalid xid length: %d\n", len(a))
+	}
+	if _, err := DecodeXID(a); err != nil {
+		t.Fatalf("[XID]: Failed to decode generated xid: %s\n", err)
+	}
+	b, _ := GenerateXID()
+	if a == b || MatchXID(a, b) {
+		t.Fatalf("[XID]: Generated equal xids: %s\n", a)
+	}
+	if !MatchXID(a, a) || MatchXID("MTIz", "MTIz") {
+		t.Fatalf("[XID]: Invalid xid match\n")
+	}
+}
+
+// =============================================================================
+// Test PAReq / PARes / authorization correlation
+// =============================================================================
+func TestXIDCorrelation(t *testing.T) {
+	req, _ := UnpackPAReq(TEST_PAREQ_B64)
+	d := &ACSDecision{Status: TDS_TX_STATUS_Y, ATN: TEST_V_I_ATN, CAVVKeyID: TEST_V_I_CAVV_KEY_ID}
+	res, err := NewPARes(req, TEST_V_PAN_16, CARD_BRAND_VISA, d, NewSoftwareCryptoProvider(keyAV, keyBV), nil)
+	if err != nil {
+		t.Fatalf("[XID]: Failed to build PARes: %s\n", err)
+	}
+	if err := CheckPAResCorrelation(req, res); err != nil {
+		t.Fatalf("[XID]: PARes does not correlate with PAReq: %s\n", err)
+	}
+	auth, err := res.AuthorizationData()
+	if err != nil {
+		t.Fatalf("[XID]: Failed to get authorization data: %s\n", err)
+	}
+	if !auth.MatchXID(req.Purchase.XID) || auth.XIDString() != req.Purchase.XID || auth.ECI != "05" || len(auth.CAVV) != 20 {
+		t.Fatalf("[XID]: Invalid authorization data: %+v\n", auth)
+	}
+
+	// PARes of another transaction
+	other := *res
+	other.Purchase.XID, _ = GenerateXID()
+	if err := CheckPAResCorrelation(req, &other); err == nil {
+		t.Fatalf("[XID]: PARes with other xid correlates with PAReq\n")
+	}
+	if auth.MatchXID(other.Purchase.XID) {
+		t.Fatalf("[XID]: Authorization data matches other xid\n")
+	}
+	other = *res
+	other.Purchase.Date = other.Purchase.Date.Add(time.Second)
+	if err := CheckPAResCorrelation(req, &other); err == nil {
+		t.Fatalf("[XID]: PARes with other purchase date correlates with PAReq\n")
+	}
+	other = *res
+	other.TX.Status = TDS_TX_STATUS_N
+	if _, err := other.AuthorizationData(); err == nil {
+		t.Fatalf("[XID]: Authorization data for not authenticated PARes\n")
+	}
+}
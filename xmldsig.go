@@ -0,0 +1,523 @@
+package gocavv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+/*
+3-D Secure 1.0.2 message signature (XML-DSig), the Signature element follows PARes in Message element
+and references it by id:
+
+	<Message id="...">
+		<PARes id="PARes1">...</PARes>
+		<Signature xmlns="http://www.w3.org/2000/09/xmldsig#">
+			<SignedInfo>
+				<CanonicalizationMethod Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/>
+				<SignatureMethod Algorithm="http://www.w3.org/2000/09/xmldsig#rsa-sha1"/>
+				<Reference URI="#PARes1">
+					<DigestMethod Algorithm="http://www.w3.org/2000/09/xmldsig#sha1"/>
+					<DigestValue>...</DigestValue>
+				</Reference>
+			</SignedInfo>
+			<SignatureValue>...</SignatureValue>
+			<KeyInfo><X509Data><X509Certificate>...</X509Certificate>...</X509Data></KeyInfo>
+		</Signature>
+	</Message>
+
+Only inclusive canonicalization (C14N 1.0 without comments) is supported.
+*/
+
+const (
+	XMLDSIG_NS                  string = "http://www.w3.org/2000/09/xmldsig#"
+	XMLDSIG_C14N                string = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	XMLDSIG_ENVELOPED_SIGNATURE string = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	XMLDSIG_RSA_SHA1            string = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	XMLDSIG_RSA_SHA256          string = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	XMLDSIG_SHA1                string = "http://www.w3.org/2000/09/xmldsig#sha1"
+	XMLDSIG_SHA256              string = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+// =============================================================================
+//  Trust store of scheme root certificates (VISA, Master Card, Mir, ...) used
+//  to verify message signer certificate chain
+// =============================================================================
+type TrustStore struct {
+	roots *x509.CertPool
+	// Verification time, current time if nil
+	CurrentTime func() time.Time
+}
+
+// =============================================================================
+//  Create trust store with root certificates
+// =============================================================================
+func NewTrustStore(roots ...*x509.Certificate) *TrustStore {
+	ts := &TrustStore{roots: x509.NewCertPool()}
+	for _, c := range roots {
+		ts.roots.AddCert(c)
+	}
+	return ts
+}
+
+// =============================================================================
+//  Add PEM encoded root certificates to trust store
+// =============================================================================
+func (ts *TrustStore) AddPEM(data []byte) error {
+	if ts.roots == nil {
+		ts.roots = x509.NewCertPool()
+	}
+	n := 0
+	for {
+		var b *pem.Block
+		if b, data = pem.Decode(data); b == nil {
+			break
+		}
+		if b.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return err
+		}
+		ts.roots.AddCert(c)
+		n++
+	}
+	if n == 0 {
+		return fmt.Errorf("No certificates found in PEM data")
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to verify signer certificate chain
+// =============================================================================
+func (ts *TrustStore) verify(signer *x509.Certificate, intermediates []*x509.Certificate) error {
+	// Never fall back to system roots
+	if ts == nil || ts.roots == nil {
+		return fmt.Errorf("Trust store has no root certificates")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         ts.roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, c := range intermediates {
+		opts.Intermediates.AddCert(c)
+	}
+	if ts.CurrentTime != nil {
+		opts.CurrentTime = ts.CurrentTime()
+	}
+	_, err := signer.Verify(opts)
+	return err
+}
+
+// =============================================================================
+//  Verify PARes signature of 3-D Secure 1.0.2 XML message against trust
+//  store, returns signer certificate
+// =============================================================================
+func VerifyPAResSignature(data []byte, ts *TrustStore) (*x509.Certificate, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, err
+	}
+	root := doc.Root()
+	if root == nil || root.Tag != "ThreeDSecure" {
+		return nil, fmt.Errorf("Invalid 3-D Secure root element")
+	}
+	msg := root.SelectElement("Message")
+	if msg == nil {
+		return nil, fmt.Errorf("3-D Secure Message element is missing")
+	}
+	// Exactly one PARes must be present, it is the only element signature may reference
+	prs := msg.SelectElements("PARes")
+	if len(prs) != 1 {
+		return nil, fmt.Errorf("Invalid number of PARes elements: %d", len(prs))
+	}
+	return verifyXMLSignature(msg, prs[0], ts)
+}
+
+// =============================================================================
+//  Helper function to verify Signature child of parent which must reference
+//  signed element
+// =============================================================================
+func verifyXMLSignature(parent, signed *etree.Element, ts *TrustStore) (*x509.Certificate, error) {
+	var sig *etree.Element
+
+	for _, c := range parent.ChildElements() {
+		if c.Tag == "Signature" && xmlNamespaceURI(c) == XMLDSIG_NS {
+			if sig != nil {
+				return nil, fmt.Errorf("Multiple Signature elements")
+			}
+			sig = c
+		}
+	}
+	if sig == nil {
+		return nil, fmt.Errorf("Signature element is missing")
+	}
+	si := dsigChild(sig, "SignedInfo")
+	if si == nil {
+		return nil, fmt.Errorf("SignedInfo element is missing")
+	}
+	if alg := dsigAlgorithm(si, "CanonicalizationMethod"); alg != XMLDSIG_C14N {
+		return nil, fmt.Errorf("Unsupported canonicalization method: %q", alg)
+	}
+	sigHash, err := xmlSignatureHash(dsigAlgorithm(si, "SignatureMethod"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Reference must point to signed element
+	refs := dsigChildren(si, "Reference")
+	if len(refs) != 1 {
+		return nil, fmt.Errorf("Invalid number of Reference elements: %d", len(refs))
+	}
+	ref := refs[0]
+	id := signed.SelectAttrValue("id", "")
+	if id == "" || ref.SelectAttrValue("URI", "") != "#"+id {
+		return nil, fmt.Errorf("Signature reference %q does not match signed element id %q", ref.SelectAttrValue("URI", ""), id)
+	}
+	var exclude *etree.Element
+	if tr := dsigChild(ref, "Transforms"); tr != nil {
+		for _, t := range dsigChildren(tr, "Transform") {
+			switch t.SelectAttrValue("Algorithm", "") {
+			case XMLDSIG_ENVELOPED_SIGNATURE:
+				exclude = sig
+			case XMLDSIG_C14N:
+			default:
+				return nil, fmt.Errorf("Unsupported transform: %q", t.SelectAttrValue("Algorithm", ""))
+			}
+		}
+	}
+	digestHash, err := xmlDigestHash(dsigAlgorithm(ref, "DigestMethod"))
+	if err != nil {
+		return nil, err
+	}
+	digest, err := base64.StdEncoding.DecodeString(dsigText(ref, "DigestValue"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DigestValue: %s", err)
+	}
+	h := digestHash.New()
+	h.Write(canonicalizeXML(signed, exclude))
+	if subtle.ConstantTimeCompare(h.Sum(nil), digest) != 1 {
+		return nil, fmt.Errorf("Signature reference digest mismatch")
+	}
+
+	// Signer certificate chain from KeyInfo
+	var chain []*x509.Certificate
+	if ki := dsigChild(sig, "KeyInfo"); ki != nil {
+		for _, xd := range dsigChildren(ki, "X509Data") {
+			for _, xc := range dsigChildren(xd, "X509Certificate") {
+				der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(xc.Text()), ""))
+				if err != nil {
+					return nil, fmt.Errorf("Invalid X509Certificate encoding: %s", err)
+				}
+				c, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, err
+				}
+				chain = append(chain, c)
+			}
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("Signer certificate is missing")
+	}
+	if err := ts.verify(chain[0], chain[1:]); err != nil {
+		return nil, err
+	}
+	pub, ok := chain[0].PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported signer public key type: %T", chain[0].PublicKey)
+	}
+
+	// Signature value over canonicalized SignedInfo
+	sv, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(dsigText(sig, "SignatureValue")), ""))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid SignatureValue encoding: %s", err)
+	}
+	h = sigHash.New()
+	h.Write(canonicalizeXML(si, nil))
+	if err := rsa.VerifyPKCS1v15(pub, sigHash, h.Sum(nil), sv); err != nil {
+		return nil, fmt.Errorf("Signature verification failed: %s", err)
+	}
+	return chain[0], nil
+}
+
+// =============================================================================
+//  Helper function to sign element: Signature element referencing signed
+//  element by id is appended to parent. Chain starts with signer certificate
+// =============================================================================
+func signXMLElement(parent, signed *etree.Element, signer crypto.Signer, chain []*x509.Certificate, hash crypto.Hash) error {
+	var sigAlg, digestAlg string
+
+	switch hash {
+	case crypto.SHA1:
+		sigAlg, digestAlg = XMLDSIG_RSA_SHA1, XMLDSIG_SHA1
+	case crypto.SHA256:
+		sigAlg, digestAlg = XMLDSIG_RSA_SHA256, XMLDSIG_SHA256
+	default:
+		return fmt.Errorf("Unsupported signature hash function: %d", hash)
+	}
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+		return fmt.Errorf("Unsupported signer key type: %T", signer.Public())
+	}
+	id := signed.SelectAttrValue("id", "")
+	if id == "" {
+		return fmt.Errorf("Signed element has no id")
+	}
+
+	h := hash.New()
+	h.Write(canonicalizeXML(signed, nil))
+
+	sig := parent.CreateElement("Signature")
+	sig.CreateAttr("xmlns", XMLDSIG_NS)
+	si := sig.CreateElement("SignedInfo")
+	si.CreateElement("CanonicalizationMethod").CreateAttr("Algorithm", XMLDSIG_C14N)
+	si.CreateElement("SignatureMethod").CreateAttr("Algorithm", sigAlg)
+	ref := si.CreateElement("Reference")
+	ref.CreateAttr("URI", "#"+id)
+	ref.CreateElement("DigestMethod").CreateAttr("Algorithm", digestAlg)
+	ref.CreateElement("DigestValue").SetText(base64.StdEncoding.EncodeToString(h.Sum(nil)))
+
+	h = hash.New()
+	h.Write(canonicalizeXML(si, nil))
+	sv, err := signer.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		parent.RemoveChild(sig)
+		return err
+	}
+	sig.CreateElement("SignatureValue").SetText(base64.StdEncoding.EncodeToString(sv))
+
+	if len(chain) > 0 {
+		xd := sig.CreateElement("KeyInfo").CreateElement("X509Data")
+		for _, c := range chain {
+			xd.CreateElement("X509Certificate").SetText(base64.StdEncoding.EncodeToString(c.Raw))
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+//  Helper function to map signature method to hash function
+// =============================================================================
+func xmlSignatureHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case XMLDSIG_RSA_SHA1:
+		return crypto.SHA1, nil
+	case XMLDSIG_RSA_SHA256:
+		return crypto.SHA256, nil
+	}
+	return 0, fmt.Errorf("Unsupported signature method: %q", alg)
+}
+
+// =============================================================================
+//  Helper function to map digest method to hash function
+// =============================================================================
+func xmlDigestHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case XMLDSIG_SHA1:
+		return crypto.SHA1, nil
+	case XMLDSIG_SHA256:
+		return crypto.SHA256, nil
+	}
+	return 0, fmt.Errorf("Unsupported digest method: %q", alg)
+}
+
+// =============================================================================
+//  Helper functions to select XML-DSig child elements (any prefix)
+// =============================================================================
+func dsigChildren(e *etree.Element, tag string) []*etree.Element {
+	var r []*etree.Element
+	for _, c := range e.ChildElements() {
+		if c.Tag == tag && xmlNamespaceURI(c) == XMLDSIG_NS {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+func dsigChild(e *etree.Element, tag string) *etree.Element {
+	if r := dsigChildren(e, tag); len(r) == 1 {
+		return r[0]
+	}
+	return nil
+}
+
+func dsigAlgorithm(e *etree.Element, tag string) string {
+	if c := dsigChild(e, tag); c != nil {
+		return c.SelectAttrValue("Algorithm", "")
+	}
+	return ""
+}
+
+func dsigText(e *etree.Element, tag string) string {
+	if c := dsigChild(e, tag); c != nil {
+		return strings.TrimSpace(c.Text())
+	}
+	return ""
+}
+
+// =============================================================================
+//  Helper function to resolve namespace URI of element prefix
+// =============================================================================
+func xmlNamespaceURI(e *etree.Element) string {
+	return xmlInScopeNamespaces(e)[e.Space]
+}
+
+// =============================================================================
+//  Helper function to collect namespace declarations in scope of element
+//  (prefix -> URI, "" for default namespace)
+// =============================================================================
+func xmlInScopeNamespaces(e *etree.Element) map[string]string {
+	var path []*etree.Element
+	for p := e; p != nil; p = p.Parent() {
+		path = append(path, p)
+	}
+	ns := make(map[string]string)
+	for i := len(path) - 1; i >= 0; i-- {
+		for _, a := range path[i].Attr {
+			if a.Space == "xmlns" {
+				ns[a.Key] = a.Value
+			} else if a.Space == "" && a.Key == "xmlns" {
+				ns[""] = a.Value
+			}
+		}
+	}
+	return ns
+}
+
+// =============================================================================
+//  Canonical XML 1.0 (inclusive, without comments) of element subtree. The
+//  apex element renders all namespace declarations in scope, exclude element
+//  (enveloped signature) is omitted
+// =============================================================================
+func canonicalizeXML(e *etree.Element, exclude *etree.Element) []byte {
+	var b bytes.Buffer
+
+	// Namespaces rendered by output ancestors: none for apex element
+	inherited := xmlInScopeNamespaces(e.Parent())
+	for k, v := range inherited {
+		if v == "" {
+			delete(inherited, k)
+		}
+	}
+	c14nElement(&b, e, exclude, map[string]string{}, inherited)
+	return b.Bytes()
+}
+
+// =============================================================================
+//  Helper function to render element: rendered - namespaces in effect in
+//  output, scope - namespaces in scope of parent element
+// =============================================================================
+func c14nElement(b *bytes.Buffer, e, exclude *etree.Element, rendered, scope map[string]string) {
+	// Namespaces in scope of element
+	inScope := make(map[string]string, len(scope))
+	for k, v := range scope {
+		inScope[k] = v
+	}
+	type attr struct{ uri, local, name, value string }
+	var attrs []attr
+	for _, a := range e.Attr {
+		if a.Space == "xmlns" {
+			inScope[a.Key] = a.Value
+		} else if a.Space == "" && a.Key == "xmlns" {
+			inScope[""] = a.Value
+		}
+	}
+	for _, a := range e.Attr {
+		if a.Space == "xmlns" || (a.Space == "" && a.Key == "xmlns") {
+			continue
+		}
+		name, uri := a.Key, ""
+		if a.Space != "" {
+			name, uri = a.Space+":"+a.Key, inScope[a.Space]
+			if a.Space == "xml" {
+				uri = "http://www.w3.org/XML/1998/namespace"
+			}
+		}
+		attrs = append(attrs, attr{uri, a.Key, name, a.Value})
+	}
+
+	// Namespace declarations differing from output ancestors
+	var prefixes []string
+	out := make(map[string]string, len(rendered))
+	for k, v := range rendered {
+		out[k] = v
+	}
+	for p, uri := range inScope {
+		if rendered[p] == uri || (p == "" && uri == "" && rendered[""] == "") {
+			continue
+		}
+		prefixes = append(prefixes, p)
+		out[p] = uri
+	}
+	sort.Strings(prefixes)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].uri != attrs[j].uri {
+			return attrs[i].uri < attrs[j].uri
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := e.Tag
+	if e.Space != "" {
+		name = e.Space + ":" + e.Tag
+	}
+	b.WriteString("<" + name)
+	for _, p := range prefixes {
+		if p == "" {
+			b.WriteString(` xmlns="` + c14nEscape(inScope[p], true) + `"`)
+		} else {
+			b.WriteString(` xmlns:` + p + `="` + c14nEscape(inScope[p], true) + `"`)
+		}
+	}
+	for _, a := range attrs {
+		b.WriteString(" " + a.name + `="` + c14nEscape(a.value, true) + `"`)
+	}
+	b.WriteString(">")
+
+	for _, t := range e.Child {
+		switch v := t.(type) {
+		case *etree.Element:
+			if v != exclude {
+				c14nElement(b, v, exclude, out, inScope)
+			}
+		case *etree.CharData:
+			b.WriteString(c14nEscape(v.Data, false))
+		case *etree.ProcInst:
+			b.WriteString("<?" + v.Target)
+			if v.Inst != "" {
+				b.WriteString(" " + v.Inst)
+			}
+			b.WriteString("?>")
+		}
+	}
+	b.WriteString("</" + name + ">")
+}
+
+// =============================================================================
+//  Helper function to escape text or attribute value for canonical XML
+// =============================================================================
+func c14nEscape(s string, attr bool) string {
+	var r *strings.Replacer
+	if attr {
+		r = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+	} else {
+		r = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	}
+	return r.Replace(s)
+}
@@ -0,0 +1,159 @@
+package gocavv
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// =============================================================================
+//  Helper function to create test certificate signed by parent (self signed
+//  if parent is nil)
+// =============================================================================
+func testCertificate(t *testing.T, cn string, ca bool, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("[DSIG]: Failed to generate RSA key: %s\n", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+	if ca {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("[DSIG]: Failed to create certificate: %s\n", err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("[DSIG]: Failed to parse certificate: %s\n", err)
+	}
+	return c, key
+}
+
+// =============================================================================
+//  Helper function to sign test PARes message
+// =============================================================================
+func testSignedPARes(t *testing.T, key crypto.Signer, chain []*x509.Certificate, h crypto.Hash) []byte {
+	data, err := UnpackThreeDSMessage(testPAResB64(t, "A", TEST_V_RS_CAVV, "06", "2"))
+	if err != nil {
+		t.Fatalf("[DSIG]: Failed to unpack PARes: %s\n", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		t.Fatalf("[DSIG]: Failed to parse PARes: %s\n", err)
+	}
+	msg := doc.Root().SelectElement("Message")
+	if err := signXMLElement(msg, msg.SelectElement("PARes"), key, chain, h); err != nil {
+		t.Fatalf("[DSIG]: Failed to sign PARes: %s\n", err)
+	}
+	if data, err = doc.WriteToBytes(); err != nil {
+		t.Fatalf("[DSIG]: Failed to write PARes: %s\n", err)
+	}
+	return data
+}
+
+// =============================================================================
+// Test inclusive XML canonicalization
+// =============================================================================
+func TestXMLCanonicalization(t *testing.T) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(`<?xml version="1.0"?><doc xmlns="http://a"><e1   /><e2 b="2" a="1&#x9;&lt;" xmlns:x="http://x" x:c="3"><e3 xmlns="http://a">&gt;&amp;</e3></e2></doc>`); err != nil {
+		t.Fatalf("[DSIG]: Failed to parse XML: %s\n", err)
+	}
+	exp := `<doc xmlns="http://a"><e1></e1><e2 xmlns:x="http://x" a="1&#x9;&lt;" b="2" x:c="3"><e3>&gt;&amp;</e3></e2></doc>`
+	if c := string(canonicalizeXML(doc.Root(), nil)); c != exp {
+		t.Fatalf("[DSIG]: Invalid canonical XML:\n%s\nexpected:\n%s\n", c, exp)
+	}
+	// Apex element renders namespaces in scope
+	exp = `<e2 xmlns="http://a" xmlns:x="http://x" a="1&#x9;&lt;" b="2" x:c="3"><e3>&gt;&amp;</e3></e2>`
+	if c := string(canonicalizeXML(doc.Root().SelectElement("e2"), nil)); c != exp {
+		t.Fatalf("[DSIG]: Invalid canonical XML:\n%s\nexpected:\n%s\n", c, exp)
+	}
+}
+
+// =============================================================================
+// Test PARes signature verification
+// =============================================================================
+func TestPAResSignature(t *testing.T) {
+	root, rootKey := testCertificate(t, "Test Scheme Root CA", true, nil, nil)
+	ica, icaKey := testCertificate(t, "Test Scheme Issuing CA", true, root, rootKey)
+	acs, acsKey := testCertificate(t, "Test ACS", false, ica, icaKey)
+	ts := NewTrustStore(root)
+
+	for _, h := range []crypto.Hash{crypto.SHA1, crypto.SHA256} {
+		data := testSignedPARes(t, acsKey, []*x509.Certificate{acs, ica}, h)
+		signer, err := VerifyPAResSignature(data, ts)
+		if err != nil {
+			t.Fatalf("[DSIG]: Failed to verify PARes signature (%s): %s\n", h, err)
+		}
+		if signer.Subject.CommonName != "Test ACS" {
+			t.Fatalf("[DSIG]: Invalid signer certificate: %s\n", signer.Subject)
+		}
+		// Packaged message
+		b64, err := PackThreeDSMessage(data)
+		if err != nil {
+			t.Fatalf("[DSIG]: Failed to package PARes: %s\n", err)
+		}
+		r, err := PaResUnMarshalVerifiedMessage(b64, CARD_BRAND_VISA, ts)
+		if err != nil {
+			t.Fatalf("[DSIG]: Failed to unmarshal verified PARes: %s\n", err)
+		}
+		if r.Signer == nil || r.TX.Status != TDS_TX_STATUS_A {
+			t.Fatalf("[DSIG]: Invalid verified PARes: %+v\n", r)
+		}
+	}
+
+	data := string(testSignedPARes(t, acsKey, []*x509.Certificate{acs, ica}, crypto.SHA256))
+
+	// Tampered PARes content
+	if _, err := VerifyPAResSignature([]byte(strings.Replace(data, "<status>A</status>", "<status>Y</status>", 1)), ts); err == nil {
+		t.Fatalf("[DSIG]: Tampered PARes signature verified\n")
+	}
+	// Tampered SignedInfo
+	if _, err := VerifyPAResSignature([]byte(strings.Replace(data, `URI="#PARes1"`, `URI="#PARes1" Id="x"`, 1)), ts); err == nil {
+		t.Fatalf("[DSIG]: Tampered SignedInfo verified\n")
+	}
+	// Untrusted root
+	other, _ := testCertificate(t, "Other Root CA", true, nil, nil)
+	if _, err := VerifyPAResSignature([]byte(data), NewTrustStore(other)); err == nil {
+		t.Fatalf("[DSIG]: PARes signature verified with untrusted root\n")
+	}
+	// Missing intermediate certificate
+	if _, err := VerifyPAResSignature(testSignedPARes(t, acsKey, []*x509.Certificate{acs}, crypto.SHA256), ts); err == nil {
+		t.Fatalf("[DSIG]: PARes signature verified without intermediate certificate\n")
+	}
+	// Unsigned PARes
+	unsigned, _ := UnpackThreeDSMessage(testPAResB64(t, "Y", TEST_V_RS_CAVV, "05", "2"))
+	if _, err := VerifyPAResSignature(unsigned, ts); err == nil {
+		t.Fatalf("[DSIG]: Unsigned PARes verified\n")
+	}
+
+	// PEM trust store
+	pts := &TrustStore{}
+	if err := pts.AddPEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})); err != nil {
+		t.Fatalf("[DSIG]: Failed to add PEM root: %s\n", err)
+	}
+	if _, err := VerifyPAResSignature([]byte(data), pts); err != nil {
+		t.Fatalf("[DSIG]: Failed to verify PARes signature with PEM trust store: %s\n", err)
+	}
+}